@@ -16,6 +16,9 @@ type Entity struct {
 	ID        uuid.UUID `sql:"primary_key"`
 	Name      string
 	URL       string
+	Enabled   bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Host      string
+	URLRules  *string
 }
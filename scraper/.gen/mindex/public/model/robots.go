@@ -13,11 +13,15 @@ import (
 )
 
 type Robots struct {
-	ID        uuid.UUID `sql:"primary_key"`
-	EntityID  uuid.UUID
-	UploadID  uuid.UUID
-	Data      string
-	Scraped   bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID               uuid.UUID `sql:"primary_key"`
+	EntityID         uuid.UUID
+	UploadID         uuid.UUID
+	Data             string
+	StatusCode       int32
+	Policy           string
+	Etag             *string
+	HTTPLastModified *string
+	Scraped          bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
@@ -13,14 +13,17 @@ import (
 )
 
 type SitemapIndex struct {
-	ID           uuid.UUID `sql:"primary_key"`
-	EntityID     uuid.UUID
-	UploadID     uuid.UUID
-	RobotsID     uuid.UUID
-	OriginID     *uuid.UUID
-	URL          string
-	LastModified time.Time
-	Scraped      bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID               uuid.UUID `sql:"primary_key"`
+	EntityID         uuid.UUID
+	UploadID         uuid.UUID
+	RobotsID         uuid.UUID
+	OriginID         *uuid.UUID
+	URL              string
+	LastModified     *time.Time
+	Etag             *string
+	HTTPLastModified *string
+	Scraped          bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CanonicalURL     string
 }
@@ -19,9 +19,13 @@ type SitemapUrlset struct {
 	RobotsID     uuid.UUID
 	OriginID     *uuid.UUID
 	URL          string
-	LastModified time.Time
+	LastModified *time.Time
 	ChangeFreq   *string
 	Scraped      bool
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	Priority     *float32
+	Source       string
+	Depth        int32
+	CanonicalURL string
 }
@@ -20,8 +20,11 @@ type entityTable struct {
 	ID        postgres.ColumnString
 	Name      postgres.ColumnString
 	URL       postgres.ColumnString
+	Enabled   postgres.ColumnBool
 	CreatedAt postgres.ColumnTimestampz
 	UpdatedAt postgres.ColumnTimestampz
+	Host      postgres.ColumnString
+	URLRules  postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -66,11 +69,14 @@ func newEntityTableImpl(schemaName, tableName, alias string) entityTable {
 		IDColumn        = postgres.StringColumn("id")
 		NameColumn      = postgres.StringColumn("name")
 		URLColumn       = postgres.StringColumn("url")
+		EnabledColumn   = postgres.BoolColumn("enabled")
 		CreatedAtColumn = postgres.TimestampzColumn("created_at")
 		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
-		allColumns      = postgres.ColumnList{IDColumn, NameColumn, URLColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = postgres.ColumnList{NameColumn, URLColumn, CreatedAtColumn, UpdatedAtColumn}
-		defaultColumns  = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
+		HostColumn      = postgres.StringColumn("host")
+		URLRulesColumn  = postgres.StringColumn("url_rules")
+		allColumns      = postgres.ColumnList{IDColumn, NameColumn, URLColumn, EnabledColumn, CreatedAtColumn, UpdatedAtColumn, HostColumn, URLRulesColumn}
+		mutableColumns  = postgres.ColumnList{NameColumn, URLColumn, EnabledColumn, CreatedAtColumn, UpdatedAtColumn, HostColumn, URLRulesColumn}
+		defaultColumns  = postgres.ColumnList{IDColumn, EnabledColumn, CreatedAtColumn, UpdatedAtColumn}
 	)
 
 	return entityTable{
@@ -80,8 +86,11 @@ func newEntityTableImpl(schemaName, tableName, alias string) entityTable {
 		ID:        IDColumn,
 		Name:      NameColumn,
 		URL:       URLColumn,
+		Enabled:   EnabledColumn,
 		CreatedAt: CreatedAtColumn,
 		UpdatedAt: UpdatedAtColumn,
+		Host:      HostColumn,
+		URLRules:  URLRulesColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
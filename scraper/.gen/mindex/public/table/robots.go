@@ -17,13 +17,17 @@ type robotsTable struct {
 	postgres.Table
 
 	// Columns
-	ID        postgres.ColumnString
-	EntityID  postgres.ColumnString
-	UploadID  postgres.ColumnString
-	Data      postgres.ColumnString
-	Scraped   postgres.ColumnBool
-	CreatedAt postgres.ColumnTimestampz
-	UpdatedAt postgres.ColumnTimestampz
+	ID               postgres.ColumnString
+	EntityID         postgres.ColumnString
+	UploadID         postgres.ColumnString
+	Data             postgres.ColumnString
+	StatusCode       postgres.ColumnInteger
+	Policy           postgres.ColumnString
+	Etag             postgres.ColumnString
+	HTTPLastModified postgres.ColumnString
+	Scraped          postgres.ColumnBool
+	CreatedAt        postgres.ColumnTimestampz
+	UpdatedAt        postgres.ColumnTimestampz
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -65,29 +69,37 @@ func newRobotsTable(schemaName, tableName, alias string) *RobotsTable {
 
 func newRobotsTableImpl(schemaName, tableName, alias string) robotsTable {
 	var (
-		IDColumn        = postgres.StringColumn("id")
-		EntityIDColumn  = postgres.StringColumn("entity_id")
-		UploadIDColumn  = postgres.StringColumn("upload_id")
-		DataColumn      = postgres.StringColumn("data")
-		ScrapedColumn   = postgres.BoolColumn("scraped")
-		CreatedAtColumn = postgres.TimestampzColumn("created_at")
-		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
-		allColumns      = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, DataColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = postgres.ColumnList{EntityIDColumn, UploadIDColumn, DataColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		defaultColumns  = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
+		IDColumn               = postgres.StringColumn("id")
+		EntityIDColumn         = postgres.StringColumn("entity_id")
+		UploadIDColumn         = postgres.StringColumn("upload_id")
+		DataColumn             = postgres.StringColumn("data")
+		StatusCodeColumn       = postgres.IntegerColumn("status_code")
+		PolicyColumn           = postgres.StringColumn("policy")
+		EtagColumn             = postgres.StringColumn("etag")
+		HTTPLastModifiedColumn = postgres.StringColumn("http_last_modified")
+		ScrapedColumn          = postgres.BoolColumn("scraped")
+		CreatedAtColumn        = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn        = postgres.TimestampzColumn("updated_at")
+		allColumns             = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, DataColumn, StatusCodeColumn, PolicyColumn, EtagColumn, HTTPLastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
+		mutableColumns         = postgres.ColumnList{EntityIDColumn, UploadIDColumn, DataColumn, StatusCodeColumn, PolicyColumn, EtagColumn, HTTPLastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
+		defaultColumns         = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
 	)
 
 	return robotsTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:        IDColumn,
-		EntityID:  EntityIDColumn,
-		UploadID:  UploadIDColumn,
-		Data:      DataColumn,
-		Scraped:   ScrapedColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
+		ID:               IDColumn,
+		EntityID:         EntityIDColumn,
+		UploadID:         UploadIDColumn,
+		Data:             DataColumn,
+		StatusCode:       StatusCodeColumn,
+		Policy:           PolicyColumn,
+		Etag:             EtagColumn,
+		HTTPLastModified: HTTPLastModifiedColumn,
+		Scraped:          ScrapedColumn,
+		CreatedAt:        CreatedAtColumn,
+		UpdatedAt:        UpdatedAtColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
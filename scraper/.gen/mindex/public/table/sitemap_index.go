@@ -17,16 +17,19 @@ type sitemapIndexTable struct {
 	postgres.Table
 
 	// Columns
-	ID           postgres.ColumnString
-	EntityID     postgres.ColumnString
-	UploadID     postgres.ColumnString
-	RobotsID     postgres.ColumnString
-	OriginID     postgres.ColumnString
-	URL          postgres.ColumnString
-	LastModified postgres.ColumnTimestampz
-	Scraped      postgres.ColumnBool
-	CreatedAt    postgres.ColumnTimestampz
-	UpdatedAt    postgres.ColumnTimestampz
+	ID               postgres.ColumnString
+	EntityID         postgres.ColumnString
+	UploadID         postgres.ColumnString
+	RobotsID         postgres.ColumnString
+	OriginID         postgres.ColumnString
+	URL              postgres.ColumnString
+	LastModified     postgres.ColumnTimestampz
+	Etag             postgres.ColumnString
+	HTTPLastModified postgres.ColumnString
+	Scraped          postgres.ColumnBool
+	CreatedAt        postgres.ColumnTimestampz
+	UpdatedAt        postgres.ColumnTimestampz
+	CanonicalURL     postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -68,35 +71,41 @@ func newSitemapIndexTable(schemaName, tableName, alias string) *SitemapIndexTabl
 
 func newSitemapIndexTableImpl(schemaName, tableName, alias string) sitemapIndexTable {
 	var (
-		IDColumn           = postgres.StringColumn("id")
-		EntityIDColumn     = postgres.StringColumn("entity_id")
-		UploadIDColumn     = postgres.StringColumn("upload_id")
-		RobotsIDColumn     = postgres.StringColumn("robots_id")
-		OriginIDColumn     = postgres.StringColumn("origin_id")
-		URLColumn          = postgres.StringColumn("url")
-		LastModifiedColumn = postgres.TimestampzColumn("last_modified")
-		ScrapedColumn      = postgres.BoolColumn("scraped")
-		CreatedAtColumn    = postgres.TimestampzColumn("created_at")
-		UpdatedAtColumn    = postgres.TimestampzColumn("updated_at")
-		allColumns         = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns     = postgres.ColumnList{EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		defaultColumns     = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
+		IDColumn               = postgres.StringColumn("id")
+		EntityIDColumn         = postgres.StringColumn("entity_id")
+		UploadIDColumn         = postgres.StringColumn("upload_id")
+		RobotsIDColumn         = postgres.StringColumn("robots_id")
+		OriginIDColumn         = postgres.StringColumn("origin_id")
+		URLColumn              = postgres.StringColumn("url")
+		LastModifiedColumn     = postgres.TimestampzColumn("last_modified")
+		EtagColumn             = postgres.StringColumn("etag")
+		HTTPLastModifiedColumn = postgres.StringColumn("http_last_modified")
+		ScrapedColumn          = postgres.BoolColumn("scraped")
+		CreatedAtColumn        = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn        = postgres.TimestampzColumn("updated_at")
+		CanonicalURLColumn     = postgres.StringColumn("canonical_url")
+		allColumns             = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, EtagColumn, HTTPLastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn, CanonicalURLColumn}
+		mutableColumns         = postgres.ColumnList{EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, EtagColumn, HTTPLastModifiedColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn, CanonicalURLColumn}
+		defaultColumns         = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
 	)
 
 	return sitemapIndexTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:           IDColumn,
-		EntityID:     EntityIDColumn,
-		UploadID:     UploadIDColumn,
-		RobotsID:     RobotsIDColumn,
-		OriginID:     OriginIDColumn,
-		URL:          URLColumn,
-		LastModified: LastModifiedColumn,
-		Scraped:      ScrapedColumn,
-		CreatedAt:    CreatedAtColumn,
-		UpdatedAt:    UpdatedAtColumn,
+		ID:               IDColumn,
+		EntityID:         EntityIDColumn,
+		UploadID:         UploadIDColumn,
+		RobotsID:         RobotsIDColumn,
+		OriginID:         OriginIDColumn,
+		URL:              URLColumn,
+		LastModified:     LastModifiedColumn,
+		Etag:             EtagColumn,
+		HTTPLastModified: HTTPLastModifiedColumn,
+		Scraped:          ScrapedColumn,
+		CreatedAt:        CreatedAtColumn,
+		UpdatedAt:        UpdatedAtColumn,
+		CanonicalURL:     CanonicalURLColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -28,6 +28,10 @@ type sitemapUrlsetTable struct {
 	Scraped      postgres.ColumnBool
 	CreatedAt    postgres.ColumnTimestampz
 	UpdatedAt    postgres.ColumnTimestampz
+	Priority     postgres.ColumnFloat
+	Source       postgres.ColumnString
+	Depth        postgres.ColumnInteger
+	CanonicalURL postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -80,9 +84,13 @@ func newSitemapUrlsetTableImpl(schemaName, tableName, alias string) sitemapUrlse
 		ScrapedColumn      = postgres.BoolColumn("scraped")
 		CreatedAtColumn    = postgres.TimestampzColumn("created_at")
 		UpdatedAtColumn    = postgres.TimestampzColumn("updated_at")
-		allColumns         = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ChangeFreqColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns     = postgres.ColumnList{EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ChangeFreqColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn}
-		defaultColumns     = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn}
+		PriorityColumn     = postgres.FloatColumn("priority")
+		SourceColumn       = postgres.StringColumn("source")
+		DepthColumn        = postgres.IntegerColumn("depth")
+		CanonicalURLColumn = postgres.StringColumn("canonical_url")
+		allColumns         = postgres.ColumnList{IDColumn, EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ChangeFreqColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn, PriorityColumn, SourceColumn, DepthColumn, CanonicalURLColumn}
+		mutableColumns     = postgres.ColumnList{EntityIDColumn, UploadIDColumn, RobotsIDColumn, OriginIDColumn, URLColumn, LastModifiedColumn, ChangeFreqColumn, ScrapedColumn, CreatedAtColumn, UpdatedAtColumn, PriorityColumn, SourceColumn, DepthColumn, CanonicalURLColumn}
+		defaultColumns     = postgres.ColumnList{IDColumn, CreatedAtColumn, UpdatedAtColumn, SourceColumn, DepthColumn}
 	)
 
 	return sitemapUrlsetTable{
@@ -100,6 +108,10 @@ func newSitemapUrlsetTableImpl(schemaName, tableName, alias string) sitemapUrlse
 		Scraped:      ScrapedColumn,
 		CreatedAt:    CreatedAtColumn,
 		UpdatedAt:    UpdatedAtColumn,
+		Priority:     PriorityColumn,
+		Source:       SourceColumn,
+		Depth:        DepthColumn,
+		CanonicalURL: CanonicalURLColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
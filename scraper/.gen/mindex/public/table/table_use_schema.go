@@ -10,7 +10,12 @@ package table
 // UseSchema sets a new schema name for all generated table SQL builder types. It is recommended to invoke
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
+	DisallowedURL = DisallowedURL.FromSchema(schema)
+	Document = Document.FromSchema(schema)
 	Entity = Entity.FromSchema(schema)
+	Link = Link.FromSchema(schema)
+	Page = Page.FromSchema(schema)
+	PageBody = PageBody.FromSchema(schema)
 	Robots = Robots.FromSchema(schema)
 	SitemapIndex = SitemapIndex.FromSchema(schema)
 	SitemapUrlset = SitemapUrlset.FromSchema(schema)
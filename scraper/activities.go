@@ -5,14 +5,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"slices"
 	"time"
 
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
 	"github.com/google/uuid"
-	sitemap "github.com/oxffaa/gopher-parse-sitemap"
-	"github.com/redis/go-redis/v9"
+	"github.com/jimsmart/grobotstxt"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 	"resty.dev/v3"
 
 	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
@@ -24,34 +29,86 @@ type HTTPGetter interface {
 }
 
 type ScraperActivities struct {
-	UserAgent   string
-	HTTPClient  HTTPGetter
-	CHClient    *sql.DB
-	PGClient    *sql.DB
-	RedisClient *redis.Client
+	UserAgent  string
+	HTTPClient HTTPGetter
+	CHClient   *sql.DB
+	PGClient   *sql.DB
+	Payloads   PayloadStore
+	Limiter    *HostLimiter
+}
+
+func setConditionalHeaders(req *resty.Request, etag *string, lastModified *string) {
+	if etag != nil && *etag != "" {
+		req.SetHeader("If-None-Match", *etag)
+	}
+
+	if lastModified != nil && *lastModified != "" {
+		req.SetHeader("If-Modified-Since", *lastModified)
+	}
+}
+
+func responseValidators(resp *resty.Response) (etag *string, lastModified *string) {
+	if value := resp.Header().Get("ETag"); value != "" {
+		etag = &value
+	}
+
+	if value := resp.Header().Get("Last-Modified"); value != "" {
+		lastModified = &value
+	}
+
+	return etag, lastModified
 }
 
 type SaveRobotsArgs struct {
-	UploadID uuid.UUID `json:"upload_id"`
-	EntityID uuid.UUID `json:"entity_id"`
-	Body     string    `json:"body"`
+	UploadID     uuid.UUID `json:"upload_id"`
+	EntityID     uuid.UUID `json:"entity_id"`
+	Body         string    `json:"body"`
+	StatusCode   int       `json:"status_code"`
+	Policy       string    `json:"policy"`
+	ETag         *string   `json:"etag,omitempty"`
+	LastModified *string   `json:"last_modified,omitempty"`
 }
 
-func (sa *ScraperActivities) SaveRobots(ctx context.Context, args SaveRobotsArgs) error {
-	_, err := Robots.INSERT(Robots.EntityID, Robots.UploadID, Robots.Data, Robots.Scraped).
+// SaveRobots stores robots.txt once per upload; a retried save updates the existing row.
+func (sa *ScraperActivities) SaveRobots(ctx context.Context, args SaveRobotsArgs) (string, error) {
+	var robots model.Robots
+	err := Robots.INSERT(
+		Robots.EntityID,
+		Robots.UploadID,
+		Robots.Data,
+		Robots.StatusCode,
+		Robots.Policy,
+		Robots.Etag,
+		Robots.HTTPLastModified,
+		Robots.Scraped,
+	).
 		MODEL(model.Robots{
-			EntityID: args.EntityID,
-			UploadID: args.UploadID,
-			Data:     args.Body,
-			Scraped:  false,
+			EntityID:         args.EntityID,
+			UploadID:         args.UploadID,
+			Data:             args.Body,
+			StatusCode:       int32(args.StatusCode),
+			Policy:           args.Policy,
+			Etag:             args.ETag,
+			HTTPLastModified: args.LastModified,
+			Scraped:          false,
 		}).
-		ExecContext(ctx, sa.PGClient)
+		ON_CONFLICT(Robots.EntityID, Robots.UploadID).
+		DO_UPDATE(SET(
+			Robots.Data.SET(Robots.EXCLUDED.Data),
+			Robots.StatusCode.SET(Robots.EXCLUDED.StatusCode),
+			Robots.Policy.SET(Robots.EXCLUDED.Policy),
+			Robots.Etag.SET(Robots.EXCLUDED.Etag),
+			Robots.HTTPLastModified.SET(Robots.EXCLUDED.HTTPLastModified),
+			Robots.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+		)).
+		RETURNING(Robots.ID).
+		QueryContext(ctx, sa.PGClient, &robots)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return robots.ID.String(), nil
 }
 
 type SaveSitemapArgs struct {
@@ -59,174 +116,570 @@ type SaveSitemapArgs struct {
 	EntityID uuid.UUID  `json:"entity_id"`
 	RobotsID uuid.UUID  `json:"robots_id"`
 	OriginID *uuid.UUID `json:"origin_id"`
-	SaveID   string     `json:"save_id"`
+	SaveIDs  []string   `json:"save_ids"`
+	Limit    int        `json:"limit"`
 }
 
-func (sa *ScraperActivities) SaveSitemapIndex(ctx context.Context, args SaveSitemapArgs) error {
-	data, err := sa.RedisClient.Get(ctx, args.SaveID).Bytes()
-
-	if err != nil {
-		return fmt.Errorf("Failed to get sitemap data: %s", err)
-	}
-
-	var sitemapIndex SitemapIndexParsed
-	err = json.Unmarshal(data, &sitemapIndex)
+type SaveSitemapRootArgs struct {
+	UploadID uuid.UUID `json:"upload_id"`
+	EntityID uuid.UUID `json:"entity_id"`
+	RobotsID uuid.UUID `json:"robots_id"`
+	Url      string    `json:"url"`
+}
 
+// SaveSitemapRoot registers a sitemap that was not discovered through a sitemap index,
+// so its entries can reference it as their origin. If the sitemap is already known for this
+// upload, for example because robots.txt and an index both list it, the existing row is returned.
+func (sa *ScraperActivities) SaveSitemapRoot(ctx context.Context, args SaveSitemapRootArgs) (string, error) {
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, args.EntityID)
 	if err != nil {
-		return fmt.Errorf("Failed to parse sitemap data: %s", err)
+		return "", err
 	}
 
-	tx, err := sa.PGClient.BeginTx(ctx, &sql.TxOptions{})
-
+	canonicalUrl, err := normalizer.Normalize(args.Url)
 	if err != nil {
-		return fmt.Errorf("Failed to start DB transaction: %s", err)
+		return "", temporal.NewNonRetryableApplicationError(err.Error(), InvalidURLErrorType, err)
 	}
 
-	insertModels := make([]model.SitemapIndex, 0, len(sitemapIndex.Index))
-
-	for _, record := range sitemapIndex.Index {
-		insertModels = append(insertModels, model.SitemapIndex{
+	var sitemapIndex model.SitemapIndex
+	err = SitemapIndex.INSERT(
+		SitemapIndex.EntityID,
+		SitemapIndex.UploadID,
+		SitemapIndex.RobotsID,
+		SitemapIndex.OriginID,
+		SitemapIndex.URL,
+		SitemapIndex.CanonicalURL,
+		SitemapIndex.LastModified,
+		SitemapIndex.Scraped,
+	).
+		MODEL(model.SitemapIndex{
 			EntityID:     args.EntityID,
 			UploadID:     args.UploadID,
 			RobotsID:     args.RobotsID,
-			OriginID:     args.OriginID,
-			URL:          record.Location,
-			LastModified: time.UnixMilli(*record.LastModified),
+			OriginID:     nil,
+			URL:          args.Url,
+			CanonicalURL: canonicalUrl,
 			Scraped:      false,
-		})
+		}).
+		ON_CONFLICT(SitemapIndex.EntityID, SitemapIndex.UploadID, SitemapIndex.CanonicalURL).
+		DO_UPDATE(SET(
+			SitemapIndex.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+		)).
+		RETURNING(SitemapIndex.ID).
+		QueryContext(ctx, sa.PGClient, &sitemapIndex)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to save root sitemap: %s", err)
 	}
 
-	for batch := range slices.Chunk(insertModels, 5000) {
-		_, err = SitemapIndex.INSERT(
-			SitemapIndex.EntityID,
-			SitemapIndex.UploadID,
-			SitemapIndex.RobotsID,
-			SitemapIndex.OriginID,
-			SitemapIndex.URL,
-			SitemapIndex.LastModified,
-			SitemapIndex.Scraped,
-		).
-			MODELS(batch).
-			ExecContext(ctx, sa.PGClient)
+	return sitemapIndex.ID.String(), nil
+}
 
-		if err != nil {
-			errRollback := tx.Rollback()
+type ListSitemapIndexArgs struct {
+	UploadID uuid.UUID  `json:"upload_id"`
+	EntityID uuid.UUID  `json:"entity_id"`
+	OriginID uuid.UUID  `json:"origin_id"`
+	AfterID  *uuid.UUID `json:"after_id,omitempty"`
+	Limit    int        `json:"limit"`
+}
 
-			if errRollback != nil {
-				return fmt.Errorf("Failed to rollback transaction: %s", err)
-			}
+type SitemapIndexEntry struct {
+	ID  uuid.UUID `json:"id"`
+	Url string    `json:"url"`
+}
 
-			return fmt.Errorf("Failed to save urlset: %s", err)
-		}
+func (sa *ScraperActivities) ListSitemapIndex(ctx context.Context, args ListSitemapIndexArgs) ([]SitemapIndexEntry, error) {
+	condition := SitemapIndex.EntityID.EQ(UUID(args.EntityID)).
+		AND(SitemapIndex.UploadID.EQ(UUID(args.UploadID))).
+		AND(SitemapIndex.OriginID.EQ(UUID(args.OriginID))).
+		AND(SitemapIndex.Scraped.IS_FALSE())
+
+	if args.AfterID != nil {
+		condition = condition.AND(SitemapIndex.ID.GT(UUID(*args.AfterID)))
+	}
+
+	var rows []model.SitemapIndex
+	err := SELECT(SitemapIndex.ID, SitemapIndex.URL).
+		FROM(SitemapIndex).
+		WHERE(condition).
+		ORDER_BY(SitemapIndex.ID.ASC()).
+		LIMIT(int64(args.Limit)).
+		QueryContext(ctx, sa.PGClient, &rows)
+
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("Failed to list sitemap index: %s", err)
 	}
 
-	err = tx.Commit()
+	entries := make([]SitemapIndexEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, SitemapIndexEntry{
+			ID:  row.ID,
+			Url: row.URL,
+		})
+	}
+
+	return entries, nil
+}
+
+type MarkSitemapIndexScrapedArgs struct {
+	ID           uuid.UUID `json:"id"`
+	ETag         *string   `json:"etag,omitempty"`
+	LastModified *string   `json:"last_modified,omitempty"`
+}
+
+// MarkSitemapIndexScraped also stores the validators of the fetched sitemap, so they are only
+// persisted once everything found in it has been saved.
+func (sa *ScraperActivities) MarkSitemapIndexScraped(ctx context.Context, args MarkSitemapIndexScrapedArgs) error {
+	_, err := SitemapIndex.UPDATE(
+		SitemapIndex.Scraped,
+		SitemapIndex.Etag,
+		SitemapIndex.HTTPLastModified,
+		SitemapIndex.UpdatedAt,
+	).
+		MODEL(model.SitemapIndex{
+			Scraped:          true,
+			Etag:             args.ETag,
+			HTTPLastModified: args.LastModified,
+			UpdatedAt:        time.Now(),
+		}).
+		WHERE(SitemapIndex.ID.EQ(UUID(args.ID))).
+		ExecContext(ctx, sa.PGClient)
 
 	if err != nil {
-		return fmt.Errorf("Failed to commit transaction: %s", err)
+		return fmt.Errorf("Failed to mark sitemap index as scraped: %s", err)
 	}
 
 	return nil
 }
 
-func (sa *ScraperActivities) SaveSitemapUrlset(ctx context.Context, args SaveSitemapArgs) error {
-	data, err := sa.RedisClient.Get(ctx, args.SaveID).Bytes()
+type CountSitemapProgressArgs struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	UploadID uuid.UUID `json:"upload_id"`
+}
+
+// CountSitemapProgress counts the sitemaps crawled and the urlset rows saved for an upload so far.
+// Unlike the SitemapCrawlResult of a finished crawl, sitemaps that were not modified or disallowed
+// are counted as well.
+func (sa *ScraperActivities) CountSitemapProgress(ctx context.Context, args CountSitemapProgressArgs) (SitemapCrawlResult, error) {
+	var counts struct {
+		Count int64
+	}
+
+	err := SELECT(COUNT(STAR).AS("count")).
+		FROM(SitemapIndex).
+		WHERE(
+			SitemapIndex.EntityID.EQ(UUID(args.EntityID)).
+				AND(SitemapIndex.UploadID.EQ(UUID(args.UploadID))).
+				AND(SitemapIndex.Scraped.IS_TRUE()),
+		).
+		QueryContext(ctx, sa.PGClient, &counts)
 
 	if err != nil {
-		return fmt.Errorf("Failed to get sitemap data: %s", err)
+		return SitemapCrawlResult{}, fmt.Errorf("Failed to count sitemaps: %s", err)
 	}
 
-	var sitemapUrlset SitemapUrlsetParsed
-	err = json.Unmarshal(data, &sitemapUrlset)
+	result := SitemapCrawlResult{Sitemaps: int(counts.Count)}
+
+	err = SELECT(COUNT(STAR).AS("count")).
+		FROM(SitemapUrlset).
+		WHERE(
+			SitemapUrlset.EntityID.EQ(UUID(args.EntityID)).
+				AND(SitemapUrlset.UploadID.EQ(UUID(args.UploadID))),
+		).
+		QueryContext(ctx, sa.PGClient, &counts)
 
 	if err != nil {
-		return fmt.Errorf("Failed to parse sitemap data: %s", err)
+		return SitemapCrawlResult{}, fmt.Errorf("Failed to count urlset: %s", err)
 	}
 
-	tx, err := sa.PGClient.BeginTx(ctx, &sql.TxOptions{})
+	result.URLs = int(counts.Count)
 
+	return result, nil
+}
+
+// SaveSitemapIndex saves every chunk of a sitemap index in one transaction, so a failed
+// attempt leaves no partial rows behind for the retry.
+func (sa *ScraperActivities) SaveSitemapIndex(ctx context.Context, args SaveSitemapArgs) error {
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, args.EntityID)
 	if err != nil {
-		return fmt.Errorf("Failed to start DB transaction: %s", err)
+		return err
 	}
 
-	insertModels := make([]model.SitemapUrlset, 0, len(sitemapUrlset.Urlset))
+	invalid := 0
+	err = InTx(ctx, sa.PGClient, func(repo *Repository) error {
+		for chunk, saveID := range args.SaveIDs {
+			data, err := sa.getPayload(ctx, saveID)
+			if err != nil {
+				return err
+			}
 
-	for _, record := range sitemapUrlset.Urlset {
-		insertModels = append(insertModels, model.SitemapUrlset{
-			EntityID:     args.EntityID,
-			UploadID:     args.UploadID,
-			RobotsID:     args.RobotsID,
-			OriginID:     args.OriginID,
-			URL:          record.Location,
-			LastModified: time.UnixMilli(*record.LastModified),
-			ChangeFreq:   &record.ChangeFrequency,
-			Scraped:      false,
-		})
+			var sitemapIndex SitemapIndexParsed
+			err = json.Unmarshal(data, &sitemapIndex)
+
+			if err != nil {
+				return fmt.Errorf("Failed to parse sitemap data: %s", err)
+			}
+
+			rows := make([]model.SitemapIndex, 0, len(sitemapIndex.Index))
+			for _, record := range sitemapIndex.Index {
+				canonicalUrl, err := normalizer.Normalize(record.Location)
+				if err != nil {
+					invalid++
+					continue
+				}
+
+				rows = append(rows, model.SitemapIndex{
+					EntityID:     args.EntityID,
+					UploadID:     args.UploadID,
+					RobotsID:     args.RobotsID,
+					OriginID:     args.OriginID,
+					URL:          record.Location,
+					CanonicalURL: canonicalUrl,
+					LastModified: record.LastModified,
+					Scraped:      false,
+				})
+			}
+
+			err = repo.UpsertSitemapIndex(ctx, rows)
+			if err != nil {
+				return err
+			}
+
+			activity.RecordHeartbeat(ctx, chunk+1)
+		}
+
+		return nil
+	})
+
+	if err == nil && invalid > 0 {
+		activity.GetLogger(ctx).Warn("Skipped sitemap index entries with invalid URLs", "count", invalid)
 	}
 
-	for batch := range slices.Chunk(insertModels, 5000) {
-		_, err = SitemapUrlset.INSERT(
-			SitemapUrlset.EntityID,
-			SitemapUrlset.UploadID,
-			SitemapUrlset.RobotsID,
-			SitemapUrlset.OriginID,
-			SitemapUrlset.URL,
-			SitemapUrlset.LastModified,
-			SitemapUrlset.Scraped,
-		).
-			MODELS(batch).
-			ExecContext(ctx, sa.PGClient)
+	return err
+}
 
-		if err != nil {
-			errRollback := tx.Rollback()
+// SaveSitemapUrlset saves at most args.Limit urlset entries in one transaction and returns
+// how many were saved.
+func (sa *ScraperActivities) SaveSitemapUrlset(ctx context.Context, args SaveSitemapArgs) (int, error) {
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, args.EntityID)
+	if err != nil {
+		return 0, err
+	}
 
-			if errRollback != nil {
-				return fmt.Errorf("Failed to rollback transaction: %s", err)
+	saved := 0
+	invalid := 0
+
+	err = InTx(ctx, sa.PGClient, func(repo *Repository) error {
+		for chunk, saveID := range args.SaveIDs {
+			if args.Limit > 0 && saved >= args.Limit {
+				break
 			}
 
-			return fmt.Errorf("Failed to save urlset: %s", err)
+			data, err := sa.getPayload(ctx, saveID)
+			if err != nil {
+				return err
+			}
+
+			var sitemapUrlset SitemapUrlsetParsed
+			err = json.Unmarshal(data, &sitemapUrlset)
+
+			if err != nil {
+				return fmt.Errorf("Failed to parse sitemap data: %s", err)
+			}
+
+			if args.Limit > 0 && saved+len(sitemapUrlset.Urlset) > args.Limit {
+				sitemapUrlset.Urlset = sitemapUrlset.Urlset[:args.Limit-saved]
+			}
+
+			rows := make([]model.SitemapUrlset, 0, len(sitemapUrlset.Urlset))
+			for _, record := range sitemapUrlset.Urlset {
+				canonicalUrl, err := normalizer.Normalize(record.Location)
+				if err != nil {
+					invalid++
+					continue
+				}
+
+				var changeFreq *string
+				if record.ChangeFrequency != "" {
+					changeFreq = &record.ChangeFrequency
+				}
+
+				var priority *float32
+				if record.Priority != nil {
+					value := float32(*record.Priority)
+					priority = &value
+				}
+
+				rows = append(rows, model.SitemapUrlset{
+					EntityID:     args.EntityID,
+					UploadID:     args.UploadID,
+					RobotsID:     args.RobotsID,
+					OriginID:     args.OriginID,
+					URL:          record.Location,
+					CanonicalURL: canonicalUrl,
+					LastModified: record.LastModified,
+					ChangeFreq:   changeFreq,
+					Priority:     priority,
+					Scraped:      false,
+				})
+			}
+
+			err = repo.UpsertSitemapUrlset(ctx, rows)
+			if err != nil {
+				return err
+			}
+
+			saved += len(rows)
+			activity.RecordHeartbeat(ctx, chunk+1)
 		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
 	}
 
-	err = tx.Commit()
+	if invalid > 0 {
+		activity.GetLogger(ctx).Warn("Skipped urlset entries with invalid URLs", "count", invalid)
+	}
+
+	return saved, nil
+}
+
+type GetRobotsArgs struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	Url      string    `json:"url"`
+}
 
+// GetRobots fetches robots.txt following RFC 9309: 2xx bodies are parsed as rules, 4xx means
+// there are no restrictions, and server or network errors fail with RobotsUnavailableErrorType so
+// the workflow can retry for a bounded time before falling back to disallowing everything.
+//
+// When the previous robots.txt of the entity carries validators the request is conditional,
+// and a 304 returns the previous row instead of a fresh body, with NotModified set.
+func (sa *ScraperActivities) GetRobots(ctx context.Context, args GetRobotsArgs) (*Robot, error) {
+	previous, err := sa.latestRobots(ctx, args.EntityID)
 	if err != nil {
-		return fmt.Errorf("Failed to commit transaction: %s", err)
+		return nil, err
 	}
 
-	return nil
+	return sa.fetchRobots(ctx, args.Url, previous)
 }
 
-func (sa *ScraperActivities) GetRobots(ctx context.Context, url string) (string, error) {
-	resp, err := sa.HTTPClient.R().
+// fetchRobots does the request of GetRobots, with previous being the latest robots.txt saved
+// for the entity or nil.
+func (sa *ScraperActivities) fetchRobots(ctx context.Context, url string, previous *model.Robots) (*Robot, error) {
+	err := sa.waitForHost(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	req := sa.HTTPClient.R().
 		SetHeader("User-Agent", sa.UserAgent).
 		SetHeader("Accept", "text/plain").
 		SetHeader("Accept-Encoding", "gzip, deflate, br, zstd").
 		SetHeader("Set-Fetch-Dest", "document").
 		SetHeader("Set-Fetch-Mode", "navigate").
-		SetHeader("Set-Fetch-User", "?1").
-		Get(url)
+		SetHeader("Set-Fetch-User", "?1")
+
+	if previous != nil && previous.Policy == RobotsPolicyRules {
+		setConditionalHeaders(req, previous.Etag, previous.HTTPLastModified)
+	}
+
+	// Left unparsed so the body is only read up to MaxRobotsSize below.
+	resp, err := req.SetDoNotParseResponse(true).Get(url)
+
+	if errors.Is(err, ErrTooManyRedirects) {
+		return &Robot{Policy: RobotsPolicyAllowAll}, nil
+	}
 
 	if err != nil {
-		return "", err
+		return nil, temporal.NewApplicationError(fmt.Sprintf("Failed to get robots.txt: %s", err), RobotsUnavailableErrorType, 0)
 	}
 
 	defer resp.Body.Close()
 
-	robotsBody := string(resp.Bytes())
+	statusCode := resp.StatusCode()
 
-	return robotsBody, nil
+	if statusCode == http.StatusNotModified && previous != nil {
+		robotsID := previous.ID.String()
+		robots := sa.parseRobots(previous.Data, int(previous.StatusCode), previous.Policy)
+		robots.ID = &robotsID
+		robots.ETag = previous.Etag
+		robots.LastModified = previous.HTTPLastModified
+		robots.NotModified = true
+
+		return robots, sa.saveCrawlDelay(ctx, url, robots.Text)
+	}
+
+	// More than five hops is treated like an unavailable robots.txt.
+	if len(resp.RedirectHistory())-1 > MaxRobotsRedirects {
+		return &Robot{StatusCode: statusCode, Policy: RobotsPolicyAllowAll}, nil
+	}
+
+	// 429 is a 4xx, but it signals overload just like 5xx, so it is not read as "allow all".
+	if statusCode >= 500 || statusCode == http.StatusTooManyRequests {
+		return nil, temporal.NewApplicationError(fmt.Sprintf("robots.txt returned status %d", statusCode), RobotsUnavailableErrorType, statusCode)
+	}
+
+	if statusCode >= 400 {
+		return &Robot{StatusCode: statusCode, Policy: RobotsPolicyAllowAll}, nil
+	}
+
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, temporal.NewApplicationError(fmt.Sprintf("robots.txt returned status %d", statusCode), RobotsUnavailableErrorType, statusCode)
+	}
+
+	robotsData, err := io.ReadAll(io.LimitReader(resp.Body, MaxRobotsSize+1))
+	if err != nil {
+		return nil, temporal.NewApplicationError(fmt.Sprintf("Failed to read robots.txt: %s", err), RobotsUnavailableErrorType, statusCode)
+	}
+
+	// Content past the parse limit is ignored, including the line it cuts through.
+	if len(robotsData) > MaxRobotsSize {
+		robotsData = robotsData[:MaxRobotsSize]
+		if newline := bytes.LastIndexByte(robotsData, '\n'); newline >= 0 {
+			robotsData = robotsData[:newline+1]
+		}
+	}
+
+	robots := sa.parseRobots(string(robotsData), statusCode, RobotsPolicyRules)
+	robots.ETag, robots.LastModified = responseValidators(resp)
+
+	return robots, sa.saveCrawlDelay(ctx, url, robots.Text)
+}
+
+func (sa *ScraperActivities) parseRobots(robotsBody string, statusCode int, policy string) *Robot {
+	sitemaps := make([]string, 0)
+	for _, sitemapUrl := range grobotstxt.Sitemaps(robotsBody) {
+		if !slices.Contains(sitemaps, sitemapUrl) {
+			sitemaps = append(sitemaps, sitemapUrl)
+		}
+	}
+
+	return &Robot{
+		Text:       robotsBody,
+		Sitemap:    sitemaps,
+		StatusCode: statusCode,
+		Policy:     policy,
+	}
+}
+
+func (sa *ScraperActivities) saveCrawlDelay(ctx context.Context, url string, robotsBody string) error {
+	if sa.Limiter == nil {
+		return nil
+	}
+
+	crawlDelay, ok := robotsCrawlDelay(robotsBody, sa.UserAgent)
+	err := sa.Limiter.SetCrawlDelay(ctx, url, crawlDelay, ok)
+
+	if err != nil {
+		return fmt.Errorf("Failed to save crawl delay: %s", err)
+	}
+
+	return nil
+}
+
+type CheckAllowedArgs struct {
+	UploadID uuid.UUID `json:"upload_id"`
+	EntityID uuid.UUID `json:"entity_id"`
+	Url      string    `json:"url"`
+}
+
+func (sa *ScraperActivities) latestRobots(ctx context.Context, entityID uuid.UUID) (*model.Robots, error) {
+	var robots model.Robots
+	err := SELECT(Robots.AllColumns).
+		FROM(Robots).
+		WHERE(Robots.EntityID.EQ(UUID(entityID))).
+		ORDER_BY(Robots.CreatedAt.DESC()).
+		LIMIT(1).
+		QueryContext(ctx, sa.PGClient, &robots)
+
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get robots.txt: %s", err)
+	}
+
+	return &robots, nil
+}
+
+// CheckAllowed evaluates url against the latest robots.txt saved for the entity.
+// Disallowed urls are recorded together with the reason, so callers only have to skip the fetch.
+func (sa *ScraperActivities) CheckAllowed(ctx context.Context, args CheckAllowedArgs) (bool, error) {
+	robots, err := sa.latestRobots(ctx, args.EntityID)
+	if err != nil {
+		return false, err
+	}
+
+	if robots == nil {
+		return true, nil
+	}
+
+	var reason string
+	switch robots.Policy {
+	case RobotsPolicyAllowAll:
+		return true, nil
+	case RobotsPolicyDisallowAll:
+		reason = fmt.Sprintf("robots.txt unreachable (status %d), disallowing all", robots.StatusCode)
+	default:
+		if grobotstxt.AgentAllowed(robots.Data, sa.UserAgent, args.Url) {
+			return true, nil
+		}
+
+		reason = fmt.Sprintf("Disallowed by robots.txt for %s", sa.UserAgent)
+	}
+
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, args.EntityID)
+	if err != nil {
+		return false, err
+	}
+
+	canonicalUrl, err := normalizer.Normalize(args.Url)
+	if err != nil {
+		canonicalUrl = args.Url
+	}
+
+	_, err = DisallowedURL.INSERT(
+		DisallowedURL.EntityID,
+		DisallowedURL.UploadID,
+		DisallowedURL.RobotsID,
+		DisallowedURL.URL,
+		DisallowedURL.CanonicalURL,
+		DisallowedURL.Reason,
+	).
+		MODEL(model.DisallowedURL{
+			EntityID:     args.EntityID,
+			UploadID:     args.UploadID,
+			RobotsID:     robots.ID,
+			URL:          args.Url,
+			CanonicalURL: canonicalUrl,
+			Reason:       reason,
+		}).
+		ON_CONFLICT(DisallowedURL.EntityID, DisallowedURL.UploadID, DisallowedURL.CanonicalURL).
+		DO_NOTHING().
+		ExecContext(ctx, sa.PGClient)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to save disallowed url: %s", err)
+	}
+
+	return false, nil
 }
 
 type SitemapResUrlset struct {
-	Location        string `json:"location"`
-	LastModified    *int64 `json:"last_modified,omitempty"`
-	ChangeFrequency string `json:"change_frequency"`
+	Location        string     `json:"location"`
+	LastModified    *time.Time `json:"last_modified,omitempty"`
+	ChangeFrequency string     `json:"change_frequency,omitempty"`
+	Priority        *float64   `json:"priority,omitempty"`
 }
 
 type SitemapResIndex struct {
-	Location     string `json:"location"`
-	LastModified *int64 `json:"last_modified,omitempty"`
+	Location     string     `json:"location"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
 }
 
 type SitemapUrlsetParsed struct {
@@ -238,97 +691,154 @@ type SitemapIndexParsed struct {
 }
 
 type SitemapRes struct {
-	Type   string `json:"type"`
-	SaveID string `json:"save_id"`
+	Type         string   `json:"type"`
+	Format       string   `json:"format"`
+	Compression  string   `json:"compression,omitempty"`
+	SaveIDs      []string `json:"save_ids,omitempty"`
+	Count        int      `json:"count"`
+	InvalidDates int      `json:"invalid_dates,omitempty"`
+	ETag         *string  `json:"etag,omitempty"`
+	LastModified *string  `json:"last_modified,omitempty"`
+}
+
+type GetSitemapArgs struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	Url      string    `json:"url"`
 }
 
-// TODO: Should we split result save into separate activity or just do it in one swoop?
-// Currently we save JSON to the Redis, which is fetched in save activities.
-func (sa *ScraperActivities) GetSitemap(ctx context.Context, url string) (*SitemapRes, error) {
-	sitemapRes, err := sa.HTTPClient.R().
+// sitemapValidators returns the validators stored the last time url was fetched for the entity.
+func (sa *ScraperActivities) sitemapValidators(ctx context.Context, entityID uuid.UUID, url string) (*string, *string, error) {
+	var previous model.SitemapIndex
+	err := SELECT(SitemapIndex.Etag, SitemapIndex.HTTPLastModified).
+		FROM(SitemapIndex).
+		WHERE(
+			SitemapIndex.EntityID.EQ(UUID(entityID)).
+				AND(SitemapIndex.URL.EQ(String(url))).
+				AND(SitemapIndex.Scraped.IS_TRUE()).
+				AND(SitemapIndex.Etag.IS_NOT_NULL().OR(SitemapIndex.HTTPLastModified.IS_NOT_NULL())),
+		).
+		ORDER_BY(SitemapIndex.UpdatedAt.DESC()).
+		LIMIT(1).
+		QueryContext(ctx, sa.PGClient, &previous)
+
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get sitemap validators: %s", err)
+	}
+
+	return previous.Etag, previous.HTTPLastModified, nil
+}
+
+func (sa *ScraperActivities) GetSitemap(ctx context.Context, args GetSitemapArgs) (*SitemapRes, error) {
+	url := args.Url
+
+	etag, lastModified, err := sa.sitemapValidators(ctx, args.EntityID, url)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sa.waitForHost(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	req := sa.HTTPClient.R().
 		SetHeader("User-Agent", sa.UserAgent).
-		SetHeader("Accept", "application/xml").
+		SetHeader("Accept", "application/xml, text/xml;q=0.9, application/rss+xml;q=0.9, application/atom+xml;q=0.9, text/plain;q=0.8, */*;q=0.5").
 		SetHeader("Accept-Encoding", "gzip, deflate, br, zstd").
 		SetHeader("Set-Fetch-Dest", "document").
 		SetHeader("Set-Fetch-Mode", "navigate").
-		SetHeader("Set-Fetch-User", "?1").
-		Get(url)
+		SetHeader("Set-Fetch-User", "?1")
+
+	setConditionalHeaders(req, etag, lastModified)
+
+	// Left unparsed so the body is streamed through openSitemap, which caps it at MaxSitemapSize.
+	sitemapRes, err := req.SetDoNotParseResponse(true).Get(url)
 
 	if err != nil {
 		return nil, err
 	}
 
-	bodyReader := sitemapRes.Body
-	defer bodyReader.Close()
+	defer sitemapRes.Body.Close()
+
+	statusCode := sitemapRes.StatusCode()
+	if statusCode == http.StatusNotModified {
+		return &SitemapRes{
+			Type:         "not_modified",
+			ETag:         etag,
+			LastModified: lastModified,
+		}, nil
+	}
 
-	var sitemapBuffer bytes.Buffer
-	teeReader := io.TeeReader(bodyReader, &sitemapBuffer)
+	if statusCode < 200 || statusCode >= 300 {
+		message := fmt.Sprintf("Sitemap %s returned status %d", url, statusCode)
+		if statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+			return nil, temporal.NewNonRetryableApplicationError(message, SitemapStatusErrorType, nil, statusCode)
+		}
 
-	sitemapUrlsetParsed := SitemapUrlsetParsed{
-		Urlset: make([]SitemapResUrlset, 0, 500),
+		return nil, temporal.NewApplicationError(message, SitemapStatusErrorType, statusCode)
 	}
 
-	sitemapIndexParsed := SitemapIndexParsed{
-		Index: make([]SitemapResIndex, 0, 500),
+	res, err := sa.parseSitemap(ctx, sitemapRes.Body)
+	if err != nil {
+		return nil, err
 	}
 
-	urlsetErr := sitemap.Parse(teeReader, func(e sitemap.Entry) error {
-		lastModified := e.GetLastModified().UnixMilli()
-		sitemapUrlsetParsed.Urlset = append(sitemapUrlsetParsed.Urlset, SitemapResUrlset{
-			Location:        e.GetLocation(),
-			LastModified:    &lastModified,
-			ChangeFrequency: e.GetChangeFrequency(),
-		})
+	res.ETag, res.LastModified = responseValidators(sitemapRes)
 
-		return nil
-	})
+	return res, nil
+}
 
-	if urlsetErr != nil {
-		return nil, urlsetErr
+// parseSitemap parses the body in a single streaming pass and leaves the entries in the
+// payload store in chunks of SitemapChunkSize, heartbeating as it goes.
+func (sa *ScraperActivities) parseSitemap(ctx context.Context, body io.Reader) (*SitemapRes, error) {
+	bodyReader, format, compression, err := openSitemap(body)
+	if err != nil {
+		return nil, err
 	}
 
-	indexErr := sitemap.ParseIndex(&sitemapBuffer, func(e sitemap.IndexEntry) error {
-		lastModified := e.GetLastModified().UnixMilli()
-		sitemapIndexParsed.Index = append(sitemapIndexParsed.Index, SitemapResIndex{
-			Location:     e.GetLocation(),
-			LastModified: &lastModified,
-		})
-
-		return nil
+	logger := activity.GetLogger(ctx)
+	chunker := newSitemapChunker(ctx, sa.Payloads, payloadScope(ctx), logger, func(count int) {
+		activity.RecordHeartbeat(ctx, count)
 	})
 
-	if indexErr != nil {
-		return nil, indexErr
+	if format == SitemapFormatText {
+		err = parseTextSitemap(bodyReader, chunker)
+	} else {
+		format, err = parseXMLSitemap(bodyReader, chunker)
 	}
 
-	var sitemapType string
-	var resData []byte
-	saveID := uuid.New().String()
-
-	if len(sitemapIndexParsed.Index) > 0 {
-		sitemapType = "index"
-		resData, err = json.Marshal(sitemapIndexParsed)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	err = chunker.flush()
+	if err != nil {
+		return nil, err
+	}
 
-		sa.RedisClient.Set(ctx, saveID, resData, time.Duration(10)*time.Minute)
-	} else if len(sitemapUrlsetParsed.Urlset) > 0 {
-		sitemapType = "urlset"
-		resData, err = json.Marshal(sitemapUrlsetParsed)
+	if chunker.invalidDates > 0 {
+		logger.Warn("Sitemap has unparseable dates", "count", chunker.invalidDates, "entries", chunker.count)
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	sitemapType := "urlset"
+	if chunker.indexed {
+		sitemapType = "index"
+	}
 
-		sa.RedisClient.Set(ctx, saveID, resData, time.Duration(10)*time.Minute)
-	} else {
+	if chunker.count == 0 {
 		sitemapType = "empty"
 	}
 
 	return &SitemapRes{
-		Type:   sitemapType,
-		SaveID: saveID,
+		Type:         sitemapType,
+		Format:       format,
+		Compression:  compression,
+		SaveIDs:      chunker.keys,
+		Count:        chunker.count,
+		InvalidDates: chunker.invalidDates,
 	}, nil
 }
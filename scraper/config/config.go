@@ -0,0 +1,429 @@
+package config
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/client"
+	"gopkg.in/yaml.v3"
+
+	"github.com/immz4/mindex/scraper"
+)
+
+// PathEnv points at the YAML file when no path is passed on the command line.
+const PathEnv = "MINDEX_CONFIG"
+
+type Config struct {
+	Temporal   TemporalConfig   `yaml:"temporal"`
+	Postgres   PostgresConfig   `yaml:"postgres"`
+	ClickHouse ClickHouseConfig `yaml:"clickhouse"`
+	Redis      RedisConfig      `yaml:"redis"`
+	Payload    PayloadConfig    `yaml:"payload"`
+	Scraper    ScraperConfig    `yaml:"scraper"`
+}
+
+type TemporalConfig struct {
+	HostPort  string `yaml:"host_port"`
+	Namespace string `yaml:"namespace"`
+}
+
+type PostgresConfig struct {
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	Database     string `yaml:"database"`
+	SSLMode      string `yaml:"sslmode"`
+}
+
+type ClickHouseConfig struct {
+	Addr         []string `yaml:"addr"`
+	Username     string   `yaml:"username"`
+	Password     string   `yaml:"password"`
+	PasswordFile string   `yaml:"password_file"`
+	Database     string   `yaml:"database"`
+}
+
+type RedisConfig struct {
+	Addr         string `yaml:"addr"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	DB           int    `yaml:"db"`
+}
+
+const (
+	PayloadBackendRedis = "redis"
+	PayloadBackendFile  = "fs"
+	PayloadBackendS3    = "s3"
+)
+
+type PayloadConfig struct {
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl"`
+	Dir     string        `yaml:"dir"`
+	S3      S3Config      `yaml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint      string `yaml:"endpoint"`
+	Region        string `yaml:"region"`
+	Bucket        string `yaml:"bucket"`
+	Prefix        string `yaml:"prefix"`
+	AccessKey     string `yaml:"access_key"`
+	SecretKey     string `yaml:"secret_key"`
+	SecretKeyFile string `yaml:"secret_key_file"`
+	UseSSL        bool   `yaml:"use_ssl"`
+}
+
+type ScraperConfig struct {
+	UserAgent  string        `yaml:"user_agent"`
+	CrawlDelay time.Duration `yaml:"crawl_delay"`
+	Burst      int           `yaml:"burst"`
+}
+
+// Default matches the local devenv services, minus the passwords.
+func Default() Config {
+	return Config{
+		Temporal: TemporalConfig{
+			HostPort:  "127.0.0.1:7233",
+			Namespace: client.DefaultNamespace,
+		},
+		Postgres: PostgresConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "mindex",
+			SSLMode:  "disable",
+		},
+		ClickHouse: ClickHouseConfig{
+			Addr:     []string{"127.0.0.1:9000"},
+			Username: "default",
+		},
+		Redis: RedisConfig{
+			Addr: "127.0.0.1:6379",
+		},
+		Payload: PayloadConfig{
+			Backend: PayloadBackendRedis,
+			TTL:     24 * time.Hour,
+		},
+		Scraper: ScraperConfig{
+			UserAgent:  "MindexBot",
+			CrawlDelay: scraper.DefaultCrawlDelay,
+			Burst:      1,
+		},
+	}
+}
+
+// Load reads the YAML file at path (or $MINDEX_CONFIG when path is empty) on top of the
+// defaults, then applies MINDEX_* environment overrides and *_file secrets, and validates the result.
+// Without any file the defaults and environment alone are used.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path == "" {
+		path = os.Getenv(PathEnv)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read config %s: %s", path, err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+
+		err = decoder.Decode(&config)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("Failed to parse config %s: %s", path, err)
+		}
+	}
+
+	err := config.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.readSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config: %w", err)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) envOverrides() map[string]any {
+	return map[string]any{
+		"MINDEX_TEMPORAL_HOST_PORT":         &c.Temporal.HostPort,
+		"MINDEX_TEMPORAL_NAMESPACE":         &c.Temporal.Namespace,
+		"MINDEX_POSTGRES_HOST":              &c.Postgres.Host,
+		"MINDEX_POSTGRES_PORT":              &c.Postgres.Port,
+		"MINDEX_POSTGRES_USER":              &c.Postgres.User,
+		"MINDEX_POSTGRES_PASSWORD":          &c.Postgres.Password,
+		"MINDEX_POSTGRES_PASSWORD_FILE":     &c.Postgres.PasswordFile,
+		"MINDEX_POSTGRES_DATABASE":          &c.Postgres.Database,
+		"MINDEX_POSTGRES_SSLMODE":           &c.Postgres.SSLMode,
+		"MINDEX_CLICKHOUSE_ADDR":            &c.ClickHouse.Addr,
+		"MINDEX_CLICKHOUSE_USERNAME":        &c.ClickHouse.Username,
+		"MINDEX_CLICKHOUSE_PASSWORD":        &c.ClickHouse.Password,
+		"MINDEX_CLICKHOUSE_PASSWORD_FILE":   &c.ClickHouse.PasswordFile,
+		"MINDEX_CLICKHOUSE_DATABASE":        &c.ClickHouse.Database,
+		"MINDEX_REDIS_ADDR":                 &c.Redis.Addr,
+		"MINDEX_REDIS_PASSWORD":             &c.Redis.Password,
+		"MINDEX_REDIS_PASSWORD_FILE":        &c.Redis.PasswordFile,
+		"MINDEX_REDIS_DB":                   &c.Redis.DB,
+		"MINDEX_PAYLOAD_BACKEND":            &c.Payload.Backend,
+		"MINDEX_PAYLOAD_TTL":                &c.Payload.TTL,
+		"MINDEX_PAYLOAD_DIR":                &c.Payload.Dir,
+		"MINDEX_PAYLOAD_S3_ENDPOINT":        &c.Payload.S3.Endpoint,
+		"MINDEX_PAYLOAD_S3_REGION":          &c.Payload.S3.Region,
+		"MINDEX_PAYLOAD_S3_BUCKET":          &c.Payload.S3.Bucket,
+		"MINDEX_PAYLOAD_S3_PREFIX":          &c.Payload.S3.Prefix,
+		"MINDEX_PAYLOAD_S3_ACCESS_KEY":      &c.Payload.S3.AccessKey,
+		"MINDEX_PAYLOAD_S3_SECRET_KEY":      &c.Payload.S3.SecretKey,
+		"MINDEX_PAYLOAD_S3_SECRET_KEY_FILE": &c.Payload.S3.SecretKeyFile,
+		"MINDEX_PAYLOAD_S3_USE_SSL":         &c.Payload.S3.UseSSL,
+		"MINDEX_SCRAPER_USER_AGENT":         &c.Scraper.UserAgent,
+		"MINDEX_SCRAPER_CRAWL_DELAY":        &c.Scraper.CrawlDelay,
+		"MINDEX_SCRAPER_BURST":              &c.Scraper.Burst,
+	}
+}
+
+func (c *Config) applyEnv() error {
+	for name, target := range c.envOverrides() {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		switch target := target.(type) {
+		case *string:
+			*target = value
+		case *int:
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %s", name, err)
+			}
+			*target = parsed
+		case *time.Duration:
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %s", name, err)
+			}
+			*target = parsed
+		case *bool:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %s", name, err)
+			}
+			*target = parsed
+		case *[]string:
+			*target = strings.Split(value, ",")
+		}
+	}
+
+	return nil
+}
+
+// readSecrets replaces passwords with the contents of their *_file counterpart,
+// so credentials can be mounted as files instead of living in YAML or the environment.
+func (c *Config) readSecrets() error {
+	secrets := []struct {
+		file   string
+		target *string
+	}{
+		{c.Postgres.PasswordFile, &c.Postgres.Password},
+		{c.ClickHouse.PasswordFile, &c.ClickHouse.Password},
+		{c.Redis.PasswordFile, &c.Redis.Password},
+		{c.Payload.S3.SecretKeyFile, &c.Payload.S3.SecretKey},
+	}
+
+	for _, secret := range secrets {
+		if secret.file == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(secret.file)
+		if err != nil {
+			return fmt.Errorf("Failed to read secret %s: %s", secret.file, err)
+		}
+
+		*secret.target = strings.TrimRight(string(data), "\r\n")
+	}
+
+	return nil
+}
+
+// Validate reports every problem at once instead of stopping at the first one.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Temporal.HostPort == "" {
+		errs = append(errs, errors.New("temporal.host_port is required"))
+	}
+
+	if c.Postgres.Host == "" {
+		errs = append(errs, errors.New("postgres.host is required"))
+	}
+
+	if c.Postgres.Port <= 0 || c.Postgres.Port > 65535 {
+		errs = append(errs, fmt.Errorf("postgres.port %d is out of range", c.Postgres.Port))
+	}
+
+	if c.Postgres.User == "" {
+		errs = append(errs, errors.New("postgres.user is required"))
+	}
+
+	if c.Postgres.Database == "" {
+		errs = append(errs, errors.New("postgres.database is required"))
+	}
+
+	if len(c.ClickHouse.Addr) == 0 {
+		errs = append(errs, errors.New("clickhouse.addr needs at least one address"))
+	}
+
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+
+	if c.Redis.DB < 0 {
+		errs = append(errs, fmt.Errorf("redis.db %d must not be negative", c.Redis.DB))
+	}
+
+	switch c.Payload.Backend {
+	case PayloadBackendRedis:
+		if c.Payload.TTL <= 0 {
+			errs = append(errs, errors.New("payload.ttl must be positive for the redis backend"))
+		}
+	case PayloadBackendFile:
+		if c.Payload.Dir == "" {
+			errs = append(errs, errors.New("payload.dir is required for the fs backend"))
+		}
+	case PayloadBackendS3:
+		if c.Payload.S3.Endpoint == "" {
+			errs = append(errs, errors.New("payload.s3.endpoint is required for the s3 backend"))
+		}
+
+		if c.Payload.S3.Bucket == "" {
+			errs = append(errs, errors.New("payload.s3.bucket is required for the s3 backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("payload.backend %q must be one of redis, fs or s3", c.Payload.Backend))
+	}
+
+	if strings.TrimSpace(c.Scraper.UserAgent) == "" {
+		errs = append(errs, errors.New("scraper.user_agent is required"))
+	}
+
+	if c.Scraper.CrawlDelay < 0 {
+		errs = append(errs, errors.New("scraper.crawl_delay must not be negative"))
+	}
+
+	if c.Scraper.Burst < 1 {
+		errs = append(errs, errors.New("scraper.burst must be at least 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) TemporalOptions() client.Options {
+	return client.Options{
+		HostPort:  c.Temporal.HostPort,
+		Namespace: c.Temporal.Namespace,
+	}
+}
+
+func (c *Config) PostgresDSN() string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.Postgres.User, c.Postgres.Password),
+		Host:   fmt.Sprintf("%s:%d", c.Postgres.Host, c.Postgres.Port),
+		Path:   c.Postgres.Database,
+	}
+
+	if c.Postgres.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {c.Postgres.SSLMode}}.Encode()
+	}
+
+	return dsn.String()
+}
+
+func (c *Config) OpenPostgres() (*sql.DB, error) {
+	db, err := sql.Open("pgx", c.PostgresDSN())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open PG connection: %s", err)
+	}
+
+	return db, nil
+}
+
+func (c *Config) OpenClickHouse() *sql.DB {
+	return clickhouse.OpenDB(&clickhouse.Options{
+		Addr: c.ClickHouse.Addr,
+		Auth: clickhouse.Auth{
+			Database: c.ClickHouse.Database,
+			Username: c.ClickHouse.Username,
+			Password: c.ClickHouse.Password,
+		},
+	})
+}
+
+func (c *Config) NewRedis() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     c.Redis.Addr,
+		Password: c.Redis.Password,
+		DB:       c.Redis.DB,
+	})
+}
+
+// NewPayloadStore builds the configured payload backend. rdb is only used by the redis backend.
+func (c *Config) NewPayloadStore(rdb *redis.Client) (scraper.PayloadStore, error) {
+	switch c.Payload.Backend {
+	case PayloadBackendFile:
+		return &scraper.FilePayloadStore{Dir: c.Payload.Dir}, nil
+	case PayloadBackendS3:
+		s3Client, err := minio.New(c.Payload.S3.Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(c.Payload.S3.AccessKey, c.Payload.S3.SecretKey, ""),
+			Secure: c.Payload.S3.UseSSL,
+			Region: c.Payload.S3.Region,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create S3 client: %s", err)
+		}
+
+		return &scraper.S3PayloadStore{
+			Client: s3Client,
+			Bucket: c.Payload.S3.Bucket,
+			Prefix: c.Payload.S3.Prefix,
+		}, nil
+	default:
+		return &scraper.RedisPayloadStore{Client: rdb, TTL: c.Payload.TTL}, nil
+	}
+}
+
+func (c *Config) NewHostLimiter(rdb *redis.Client) *scraper.HostLimiter {
+	return &scraper.HostLimiter{
+		Client:       rdb,
+		DefaultDelay: c.Scraper.CrawlDelay,
+		Burst:        c.Scraper.Burst,
+	}
+}
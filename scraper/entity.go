@@ -0,0 +1,386 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+	"github.com/immz4/mindex/scraper/urlnorm"
+)
+
+const (
+	EntityListCSV    = "csv"
+	EntityListTranco = "tranco"
+)
+
+// entityInsertBatchSize keeps imports of large ranked lists to a sane statement size.
+const entityInsertBatchSize = 1000
+
+type EntityInput struct {
+	Name string
+	Url  string
+}
+
+// NormalizeEntityURL reduces a user supplied URL or bare domain to the site origin the
+// crawler works from (robots.txt is fetched at <origin>/robots.txt), and returns the host
+// entities are deduplicated by. Bare domains default to https.
+func NormalizeEntityURL(raw string) (origin string, host string, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", "", errors.New("URL is empty")
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to parse url %s: %s", raw, err)
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", "", fmt.Errorf("Unsupported scheme %s in %s", parsed.Scheme, raw)
+	}
+
+	hostname := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if hostname == "" {
+		return "", "", fmt.Errorf("Missing host in %s", raw)
+	}
+
+	if net.ParseIP(hostname) == nil && !strings.Contains(hostname, ".") {
+		return "", "", fmt.Errorf("Host %s is not a fully qualified domain", hostname)
+	}
+
+	port := parsed.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	originHost := hostname
+	if strings.Contains(hostname, ":") {
+		originHost = "[" + hostname + "]"
+	}
+
+	if port != "" {
+		originHost = originHost + ":" + port
+	}
+
+	return scheme + "://" + originHost, strings.TrimPrefix(hostname, "www."), nil
+}
+
+// ParseEntityList reads a bulk import. CSV files have a url column and an optional name
+// column, with or without a header row; Tranco-style lists are "rank,domain" lines.
+// Entries are normalized and deduplicated by host, keeping the first occurrence.
+// Invalid lines are returned as errors next to the valid entries instead of failing the import.
+func ParseEntityList(reader io.Reader, format string) ([]EntityInput, []error, error) {
+	records := csv.NewReader(reader)
+	records.FieldsPerRecord = -1
+	records.TrimLeadingSpace = true
+	records.Comment = '#'
+
+	urlColumn, nameColumn := 0, -1
+	switch format {
+	case EntityListCSV:
+	case EntityListTranco:
+		urlColumn = 1
+	default:
+		return nil, nil, fmt.Errorf("Unknown entity list format %s", format)
+	}
+
+	var inputs []EntityInput
+	var invalid []error
+	seen := make(map[string]bool)
+
+	for line := 1; ; line++ {
+		record, err := records.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read entity list: %s", err)
+		}
+
+		if format == EntityListCSV && line == 1 && isEntityHeader(record) {
+			urlColumn, nameColumn = -1, -1
+			for i, column := range record {
+				switch strings.ToLower(strings.TrimSpace(column)) {
+				case "url", "domain":
+					urlColumn = i
+				case "name":
+					nameColumn = i
+				}
+			}
+
+			if urlColumn < 0 {
+				return nil, nil, errors.New("Entity list header has no url column")
+			}
+
+			continue
+		}
+
+		if format == EntityListCSV && line == 1 {
+			// Headerless CSV: url, then an optional name.
+			nameColumn = 1
+		}
+
+		if format == EntityListTranco && len(record) > 0 {
+			_, err = strconv.Atoi(strings.TrimSpace(record[0]))
+			if err != nil {
+				invalid = append(invalid, fmt.Errorf("line %d: rank %q is not a number", line, record[0]))
+				continue
+			}
+		}
+
+		if urlColumn >= len(record) {
+			invalid = append(invalid, fmt.Errorf("line %d: missing url", line))
+			continue
+		}
+
+		origin, host, err := NormalizeEntityURL(record[urlColumn])
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("line %d: %s", line, err))
+			continue
+		}
+
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		name := host
+		if nameColumn >= 0 && nameColumn < len(record) && strings.TrimSpace(record[nameColumn]) != "" {
+			name = strings.TrimSpace(record[nameColumn])
+		}
+
+		inputs = append(inputs, EntityInput{Name: name, Url: origin})
+	}
+
+	return inputs, invalid, nil
+}
+
+func isEntityHeader(record []string) bool {
+	for _, column := range record {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "url", "domain", "name":
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddEntities inserts entities whose host is not known yet and returns the inserted rows.
+// Existing hosts are skipped silently, so imports can be re-run.
+func AddEntities(ctx context.Context, db *sql.DB, inputs []EntityInput) ([]model.Entity, error) {
+	added := make([]model.Entity, 0, len(inputs))
+
+	for start := 0; start < len(inputs); start += entityInsertBatchSize {
+		end := min(start+entityInsertBatchSize, len(inputs))
+
+		entities := make([]model.Entity, 0, end-start)
+		for _, input := range inputs[start:end] {
+			origin, host, err := NormalizeEntityURL(input.Url)
+			if err != nil {
+				return added, err
+			}
+
+			name := strings.TrimSpace(input.Name)
+			if name == "" {
+				name = host
+			}
+
+			entities = append(entities, model.Entity{
+				Name: name,
+				URL:  origin,
+				Host: host,
+			})
+		}
+
+		var inserted []model.Entity
+		err := Entity.INSERT(Entity.Name, Entity.URL, Entity.Host).
+			MODELS(entities).
+			ON_CONFLICT(Entity.Host).
+			DO_NOTHING().
+			RETURNING(Entity.AllColumns).
+			QueryContext(ctx, db, &inserted)
+
+		if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+			return added, fmt.Errorf("Failed to save entities: %s", err)
+		}
+
+		added = append(added, inserted...)
+	}
+
+	return added, nil
+}
+
+func ListEntities(ctx context.Context, db *sql.DB) ([]model.Entity, error) {
+	var entities []model.Entity
+	err := SELECT(Entity.AllColumns).
+		FROM(Entity).
+		ORDER_BY(Entity.CreatedAt.ASC()).
+		QueryContext(ctx, db, &entities)
+
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("Failed to list entities: %s", err)
+	}
+
+	return entities, nil
+}
+
+type UpdateEntityArgs struct {
+	ID       uuid.UUID
+	Name     *string
+	Url      *string
+	Enabled  *bool
+	URLRules *urlnorm.Rules
+}
+
+func UpdateEntity(ctx context.Context, db *sql.DB, args UpdateEntityArgs) (*model.Entity, error) {
+	var entity model.Entity
+	err := SELECT(Entity.AllColumns).
+		FROM(Entity).
+		WHERE(Entity.ID.EQ(UUID(args.ID))).
+		QueryContext(ctx, db, &entity)
+
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("Entity %s does not exist", args.ID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get entity: %s", err)
+	}
+
+	if args.Name != nil {
+		entity.Name = strings.TrimSpace(*args.Name)
+	}
+
+	if args.Url != nil {
+		entity.URL, entity.Host, err = NormalizeEntityURL(*args.Url)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if args.Enabled != nil {
+		entity.Enabled = *args.Enabled
+	}
+
+	if args.URLRules != nil {
+		entity.URLRules = nil
+
+		if len(args.URLRules.StripParams) > 0 || len(args.URLRules.KeepParams) > 0 {
+			data, err := json.Marshal(args.URLRules)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to encode URL rules: %s", err)
+			}
+
+			rules := string(data)
+			entity.URLRules = &rules
+		}
+	}
+
+	urlRules := Expression(NULL)
+	if entity.URLRules != nil {
+		urlRules = String(*entity.URLRules)
+	}
+
+	err = Entity.UPDATE(Entity.Name, Entity.URL, Entity.Host, Entity.Enabled, Entity.URLRules, Entity.UpdatedAt).
+		SET(String(entity.Name), String(entity.URL), String(entity.Host), Bool(entity.Enabled), urlRules, CURRENT_TIMESTAMP()).
+		WHERE(Entity.ID.EQ(UUID(args.ID))).
+		RETURNING(Entity.AllColumns).
+		QueryContext(ctx, db, &entity)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update entity: %s", err)
+	}
+
+	return &entity, nil
+}
+
+// EntityNormalizer returns the URL normalizer configured for an entity, canonicalizing both
+// http and https URLs to the scheme of the entity URL.
+func EntityNormalizer(ctx context.Context, db qrm.Queryable, entityID uuid.UUID) (*urlnorm.Normalizer, error) {
+	var entity model.Entity
+	err := SELECT(Entity.URL, Entity.URLRules).
+		FROM(Entity).
+		WHERE(Entity.ID.EQ(UUID(entityID))).
+		QueryContext(ctx, db, &entity)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get entity URL rules: %s", err)
+	}
+
+	var rules urlnorm.Rules
+	if entity.URLRules != nil {
+		err = json.Unmarshal([]byte(*entity.URLRules), &rules)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse entity URL rules: %s", err)
+		}
+	}
+
+	if parsed, err := url.Parse(entity.URL); err == nil {
+		rules.Scheme = parsed.Scheme
+	}
+
+	return urlnorm.New(rules), nil
+}
+
+// RemoveEntity deletes an entity together with everything crawled for it.
+func RemoveEntity(ctx context.Context, db *sql.DB, id uuid.UUID) error {
+	res, err := Entity.DELETE().
+		WHERE(Entity.ID.EQ(UUID(id))).
+		ExecContext(ctx, db)
+
+	if err != nil {
+		return fmt.Errorf("Failed to remove entity: %s", err)
+	}
+
+	removed, err := res.RowsAffected()
+	if err == nil && removed == 0 {
+		return fmt.Errorf("Entity %s does not exist", id)
+	}
+
+	return nil
+}
+
+func InitialCrawlWorkflowID(entityID string) string {
+	return fmt.Sprintf("crawl-entity-%s-initial", entityID)
+}
+
+// StartEntityCrawl kicks off the first CrawlEntity run for a new entity without waiting for
+// its schedule to fire.
+func StartEntityCrawl(ctx context.Context, temporalClient client.Client, entity model.Entity, limits *SitemapLimits) error {
+	_, err := temporalClient.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        InitialCrawlWorkflowID(entity.ID.String()),
+		TaskQueue: ScraperQueueName,
+	}, CrawlEntity, CrawlEntityArgs{
+		EntityID: entity.ID.String(),
+		Url:      entity.URL,
+		Limits:   limits,
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to start crawl for %s: %s", entity.URL, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEntityList(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []EntityInput
+		invalid int
+	}{
+		{
+			name:   "headerless url only",
+			format: EntityListCSV,
+			input:  "example.com\nhttps://www.example.org/path\n",
+			want: []EntityInput{
+				{Name: "example.com", Url: "https://example.com"},
+				{Name: "example.org", Url: "https://www.example.org"},
+			},
+		},
+		{
+			name:   "headerless url and name",
+			format: EntityListCSV,
+			input:  "example.com,Example\nexample.org\nexample.net,\n",
+			want: []EntityInput{
+				{Name: "Example", Url: "https://example.com"},
+				{Name: "example.org", Url: "https://example.org"},
+				{Name: "example.net", Url: "https://example.net"},
+			},
+		},
+		{
+			name:   "header in any order",
+			format: EntityListCSV,
+			input:  "name,url\nExample,example.com\n",
+			want: []EntityInput{
+				{Name: "Example", Url: "https://example.com"},
+			},
+		},
+		{
+			name:   "duplicate hosts and invalid lines",
+			format: EntityListCSV,
+			input:  "example.com\nwww.example.com,Other\nlocalhost\n",
+			want: []EntityInput{
+				{Name: "example.com", Url: "https://example.com"},
+			},
+			invalid: 1,
+		},
+		{
+			name:   "tranco",
+			format: EntityListTranco,
+			input:  "1,example.com\nx,example.org\n2,example.net\n",
+			want: []EntityInput{
+				{Name: "example.com", Url: "https://example.com"},
+				{Name: "example.net", Url: "https://example.net"},
+			},
+			invalid: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, invalid, err := ParseEntityList(strings.NewReader(test.input), test.format)
+			if err != nil {
+				t.Fatalf("ParseEntityList() error = %s", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseEntityList() = %v, want %v", got, test.want)
+			}
+
+			if len(invalid) != test.invalid {
+				t.Errorf("ParseEntityList() invalid = %v, want %d errors", invalid, test.invalid)
+			}
+		})
+	}
+}
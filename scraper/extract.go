@@ -0,0 +1,544 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+)
+
+const (
+	// MaxDocumentContent caps the extracted text stored per document.
+	MaxDocumentContent = 1024 * 1024
+
+	DocumentParseErrorType = "DocumentParse"
+)
+
+type DocumentHeading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+type DocumentLink struct {
+	Url      string `json:"url"`
+	Text     string `json:"text,omitempty"`
+	Rel      string `json:"rel,omitempty"`
+	NoFollow bool   `json:"nofollow,omitempty"`
+}
+
+type ExtractedDocument struct {
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	Headings     []DocumentHeading `json:"headings"`
+	Language     string            `json:"language"`
+	CanonicalUrl string            `json:"canonical_url"`
+	Content      string            `json:"content"`
+	Links        []DocumentLink    `json:"links"`
+}
+
+// boilerplateElements never contain main content.
+var boilerplateElements = []atom.Atom{
+	atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe,
+	atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Form, atom.Button, atom.Select,
+}
+
+var boilerplateRoles = []string{"navigation", "banner", "contentinfo", "complementary", "search", "menu"}
+
+// blockElements end a line of extracted text.
+var blockElements = []atom.Atom{
+	atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Li, atom.Ul, atom.Ol,
+	atom.Table, atom.Tr, atom.Td, atom.Th, atom.Blockquote, atom.Pre, atom.Br, atom.Hr,
+	atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Dd, atom.Dt, atom.Figcaption,
+}
+
+// parseDocument turns an HTML page into an indexable document. contentType is used to
+// decode non UTF-8 pages and pageUrl to resolve relative links.
+func parseDocument(body []byte, contentType string, pageUrl string) (*ExtractedDocument, error) {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := html.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &ExtractedDocument{
+		Headings: []DocumentHeading{},
+		Links:    []DocumentLink{},
+	}
+
+	var ogTitle, ogDescription string
+	seenLinks := make(map[string]bool)
+
+	// <base href> changes how every relative URL on the page resolves, so find it first.
+	for node := range root.Descendants() {
+		if node.Type == html.ElementNode && node.DataAtom == atom.Base {
+			if href := attr(node, "href"); href != "" {
+				if resolved, err := base.Parse(href); err == nil {
+					base = resolved
+				}
+			}
+
+			break
+		}
+	}
+
+	for node := range root.Descendants() {
+		if node.Type != html.ElementNode {
+			continue
+		}
+
+		switch node.DataAtom {
+		case atom.Html:
+			document.Language = strings.TrimSpace(attr(node, "lang"))
+		case atom.Title:
+			if document.Title == "" {
+				document.Title = nodeText(node)
+			}
+		case atom.Meta:
+			name := strings.ToLower(attr(node, "name"))
+			property := strings.ToLower(attr(node, "property"))
+			content := strings.TrimSpace(attr(node, "content"))
+
+			switch {
+			case name == "description":
+				document.Description = content
+			case property == "og:description":
+				ogDescription = content
+			case property == "og:title":
+				ogTitle = content
+			case strings.EqualFold(attr(node, "http-equiv"), "content-language") && document.Language == "":
+				document.Language = content
+			}
+		case atom.Link:
+			if hasToken(attr(node, "rel"), "canonical") && document.CanonicalUrl == "" {
+				document.CanonicalUrl = resolveLink(base, attr(node, "href"))
+			}
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			if text := nodeText(node); text != "" {
+				document.Headings = append(document.Headings, DocumentHeading{
+					Level: int(node.Data[1] - '0'),
+					Text:  text,
+				})
+			}
+		case atom.A:
+			link := resolveLink(base, attr(node, "href"))
+			if link == "" || seenLinks[link] {
+				continue
+			}
+			seenLinks[link] = true
+
+			rel := strings.ToLower(strings.Join(strings.Fields(attr(node, "rel")), " "))
+			document.Links = append(document.Links, DocumentLink{
+				Url:      link,
+				Text:     nodeText(node),
+				Rel:      rel,
+				NoFollow: hasToken(rel, "nofollow"),
+			})
+		}
+	}
+
+	if document.Title == "" {
+		document.Title = ogTitle
+	}
+
+	if document.Description == "" {
+		document.Description = ogDescription
+	}
+
+	document.Content = truncateUTF8(mainContent(root), MaxDocumentContent)
+
+	return document, nil
+}
+
+// mainContent extracts readable text, preferring the largest <main> or <article> and falling
+// back to <body>. Navigation-like elements and link-heavy blocks are dropped.
+func mainContent(root *html.Node) string {
+	lengths := make(map[*html.Node]textLength)
+	measureText(root, lengths)
+
+	var best *html.Node
+	bestLength := 0
+
+	for node := range root.Descendants() {
+		if node.Type != html.ElementNode || (node.DataAtom != atom.Main && node.DataAtom != atom.Article) {
+			continue
+		}
+
+		if length := lengths[node].text; length > bestLength {
+			best, bestLength = node, length
+		}
+	}
+
+	if best == nil {
+		for node := range root.Descendants() {
+			if node.Type == html.ElementNode && node.DataAtom == atom.Body {
+				best = node
+				break
+			}
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+
+	var builder strings.Builder
+	writeContent(&builder, best, lengths)
+
+	lines := strings.Split(builder.String(), "\n")
+	content := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			content = append(content, line)
+		}
+	}
+
+	return strings.Join(content, "\n")
+}
+
+func writeContent(builder *strings.Builder, node *html.Node, lengths map[*html.Node]textLength) {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		if isBoilerplate(node, lengths[node]) {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	block := slices.Contains(blockElements, node.DataAtom)
+	if block {
+		builder.WriteByte('\n')
+	}
+
+	for child := range node.ChildNodes() {
+		writeContent(builder, child, lengths)
+	}
+
+	if block {
+		builder.WriteByte('\n')
+	}
+}
+
+// textLength is the amount of text below a node, counted in bytes without whitespace, and how
+// much of it is inside links.
+type textLength struct {
+	text int
+	link int
+}
+
+// measureText records the textLength of node and of every element below it, computed bottom-up
+// in a single pass so checking nested containers does not walk their subtrees again.
+func measureText(node *html.Node, lengths map[*html.Node]textLength) textLength {
+	var length textLength
+
+	switch node.Type {
+	case html.TextNode:
+		// Like nodeText, script and style contents are not text.
+		if parent := node.Parent; parent != nil && (parent.DataAtom == atom.Script || parent.DataAtom == atom.Style) {
+			return length
+		}
+
+		for _, field := range strings.Fields(node.Data) {
+			length.text += len(field)
+		}
+
+		return length
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return length
+	}
+
+	for child := range node.ChildNodes() {
+		childLength := measureText(child, lengths)
+		length.text += childLength.text
+		length.link += childLength.link
+	}
+
+	if node.DataAtom == atom.A {
+		length.link = length.text
+	}
+
+	lengths[node] = length
+
+	return length
+}
+
+func isBoilerplate(node *html.Node, length textLength) bool {
+	if slices.Contains(boilerplateElements, node.DataAtom) {
+		return true
+	}
+
+	if hasAttr(node, "hidden") || attr(node, "aria-hidden") == "true" {
+		return true
+	}
+
+	if slices.Contains(boilerplateRoles, strings.ToLower(attr(node, "role"))) {
+		return true
+	}
+
+	// Menus and link lists: containers whose text is mostly link text.
+	if node.DataAtom == atom.Div || node.DataAtom == atom.Ul || node.DataAtom == atom.Ol || node.DataAtom == atom.Section {
+		if length.text == 0 {
+			return false
+		}
+
+		return float64(length.link)/float64(length.text) > 0.6
+	}
+
+	return false
+}
+
+// nodeText returns the whitespace-collapsed text below node, skipping scripts and styles.
+func nodeText(node *html.Node) string {
+	var builder strings.Builder
+	for descendant := range node.Descendants() {
+		if descendant.Type != html.TextNode {
+			continue
+		}
+
+		if parent := descendant.Parent; parent != nil && (parent.DataAtom == atom.Script || parent.DataAtom == atom.Style) {
+			continue
+		}
+
+		builder.WriteString(descendant.Data)
+		builder.WriteByte(' ')
+	}
+
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// resolveLink returns an absolute http(s) URL without fragment, or "" for links that do not
+// point at another document (javascript:, mailto:, in-page anchors).
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+
+	resolved, err := base.Parse(href)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") || resolved.Host == "" {
+		return ""
+	}
+
+	resolved.Fragment = ""
+	resolved.RawFragment = ""
+
+	return resolved.String()
+}
+
+func attr(node *html.Node, name string) string {
+	for _, attribute := range node.Attr {
+		if strings.EqualFold(attribute.Key, name) {
+			return attribute.Val
+		}
+	}
+
+	return ""
+}
+
+func hasAttr(node *html.Node, name string) bool {
+	for _, attribute := range node.Attr {
+		if strings.EqualFold(attribute.Key, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasToken(value string, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func truncateUTF8(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+
+	value = value[:limit]
+	for len(value) > 0 && !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+
+	return value
+}
+
+type ExtractDocumentArgs struct {
+	PageID uuid.UUID `json:"page_id"`
+}
+
+type ExtractDocumentRes struct {
+	DocumentID *string `json:"document_id,omitempty"`
+	Skipped    bool    `json:"skipped"`
+	Links      int     `json:"links"`
+	Discovered int     `json:"discovered"`
+}
+
+// ExtractDocument parses the stored body of a fetched page into a document and records its
+// outgoing links. Links to the same entity are enqueued as urlset rows so pages missing from
+// sitemaps get fetched too. Pages that are not successful HTML responses are skipped.
+func (sa *ScraperActivities) ExtractDocument(ctx context.Context, args ExtractDocumentArgs) (*ExtractDocumentRes, error) {
+	var page model.Page
+	err := SELECT(Page.AllColumns).
+		FROM(Page).
+		WHERE(Page.ID.EQ(UUID(args.PageID))).
+		QueryContext(ctx, sa.PGClient, &page)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get page: %s", err)
+	}
+
+	contentType := ""
+	if page.ContentType != nil {
+		contentType = *page.ContentType
+	}
+
+	if page.StatusCode < 200 || page.StatusCode > 299 || page.BodyRef == nil || !isHTMLContentType(contentType) {
+		return &ExtractDocumentRes{Skipped: true}, nil
+	}
+
+	var body model.PageBody
+	err = SELECT(PageBody.Data).
+		FROM(PageBody).
+		WHERE(PageBody.Hash.EQ(String(*page.BodyRef))).
+		QueryContext(ctx, sa.PGClient, &body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get page body: %s", err)
+	}
+
+	document, err := parseDocument(body.Data, contentType, page.FinalURL)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("Failed to parse page %s: %s", page.FinalURL, err), DocumentParseErrorType, err)
+	}
+
+	if document.Language == "" {
+		var headers http.Header
+		if json.Unmarshal([]byte(page.Headers), &headers) == nil {
+			document.Language = strings.TrimSpace(headers.Get("Content-Language"))
+		}
+	}
+
+	headings, err := json.Marshal(document.Headings)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode headings: %s", err)
+	}
+
+	links, err := json.Marshal(document.Links)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode links: %s", err)
+	}
+
+	source, err := sa.getLinkSource(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	if document.CanonicalUrl != "" {
+		if canonicalUrl, err := source.Normalizer.Normalize(document.CanonicalUrl); err == nil {
+			document.CanonicalUrl = canonicalUrl
+		}
+	}
+
+	var saved model.Document
+	discovered := 0
+	err = InTx(ctx, sa.PGClient, func(repo *Repository) error {
+		saved, err = repo.UpsertDocument(ctx, model.Document{
+			EntityID:     page.EntityID,
+			UploadID:     page.UploadID,
+			PageID:       page.ID,
+			URL:          page.FinalURL,
+			Title:        document.Title,
+			Description:  optionalString(document.Description),
+			Headings:     string(headings),
+			Language:     optionalString(document.Language),
+			CanonicalURL: optionalString(document.CanonicalUrl),
+			Content:      document.Content,
+			Links:        string(links),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = repo.ReplacePageLinks(ctx, page.ID, source.linkRows(document.Links))
+		if err != nil {
+			return err
+		}
+
+		discovered, err = repo.EnqueueUrlset(ctx, source.discoveredUrlset(document.Links))
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	documentID := saved.ID.String()
+
+	return &ExtractDocumentRes{
+		DocumentID: &documentID,
+		Links:      len(document.Links),
+		Discovered: discovered,
+	}, nil
+}
+
+func isHTMLContentType(contentType string) bool {
+	// Servers that omit Content-Type mostly serve HTML; the parser copes with anything else.
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
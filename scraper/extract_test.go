@@ -0,0 +1,232 @@
+package scraper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		pageUrl     string
+		check       func(t *testing.T, document *ExtractedDocument)
+	}{
+		{
+			name: "title and description",
+			body: `<html><head>
+				<title>  Page
+				title </title>
+				<meta property="og:title" content="Open Graph title">
+				<meta name="Description" content=" Meta description ">
+				<meta property="og:description" content="Open Graph description">
+			</head></html>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.Title != "Page title" || document.Description != "Meta description" {
+					t.Errorf("parseDocument() = %q, %q, want %q, %q", document.Title, document.Description, "Page title", "Meta description")
+				}
+			},
+		},
+		{
+			name: "open graph fallback",
+			body: `<html><head>
+				<meta property="og:title" content="Open Graph title">
+				<meta property="og:description" content="Open Graph description">
+			</head></html>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.Title != "Open Graph title" || document.Description != "Open Graph description" {
+					t.Errorf("parseDocument() = %q, %q, want the Open Graph title and description", document.Title, document.Description)
+				}
+			},
+		},
+		{
+			name: "headings",
+			body: `<body><h1>Main <em>heading</em></h1><h2>Second</h2><h3>  </h3><h6>Sixth</h6></body>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				want := []DocumentHeading{{1, "Main heading"}, {2, "Second"}, {6, "Sixth"}}
+				if !reflect.DeepEqual(document.Headings, want) {
+					t.Errorf("parseDocument() headings = %+v, want %+v", document.Headings, want)
+				}
+			},
+		},
+		{
+			name: "links",
+			body: `<body>
+				<a href="/a#top">A</a>
+				<a href="/a">A again</a>
+				<a href="b" rel="NoFollow  Sponsored">B</a>
+				<a href="https://other.example/">Other</a>
+				<a href="#section">Anchor</a>
+				<a href="mailto:someone@example.com">Mail</a>
+				<a href="javascript:void(0)">Script</a>
+				<a>No href</a>
+			</body>`,
+			pageUrl: "https://example.com/dir/page",
+			check: func(t *testing.T, document *ExtractedDocument) {
+				want := []DocumentLink{
+					{Url: "https://example.com/a", Text: "A"},
+					{Url: "https://example.com/dir/b", Text: "B", Rel: "nofollow sponsored", NoFollow: true},
+					{Url: "https://other.example/", Text: "Other"},
+				}
+				if !reflect.DeepEqual(document.Links, want) {
+					t.Errorf("parseDocument() links = %+v, want %+v", document.Links, want)
+				}
+			},
+		},
+		{
+			name: "base href",
+			body: `<html><head>
+				<link rel="canonical" href="canonical">
+				<base href="https://cdn.example.com/base/">
+			</head><body><a href="page">Page</a><a href="/root">Root</a></body></html>`,
+			pageUrl: "https://example.com/dir/page",
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.CanonicalUrl != "https://cdn.example.com/base/canonical" {
+					t.Errorf("parseDocument() canonical = %q, want it resolved against <base>", document.CanonicalUrl)
+				}
+
+				want := []DocumentLink{
+					{Url: "https://cdn.example.com/base/page", Text: "Page"},
+					{Url: "https://cdn.example.com/root", Text: "Root"},
+				}
+				if !reflect.DeepEqual(document.Links, want) {
+					t.Errorf("parseDocument() links = %+v, want %+v", document.Links, want)
+				}
+			},
+		},
+		{
+			name:    "relative base href",
+			body:    `<head><base href="/other/"></head><body><a href="page">Page</a></body>`,
+			pageUrl: "https://example.com/dir/page",
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if len(document.Links) != 1 || document.Links[0].Url != "https://example.com/other/page" {
+					t.Errorf("parseDocument() links = %+v, want https://example.com/other/page", document.Links)
+				}
+			},
+		},
+		{
+			name: "canonical link",
+			body: `<head>
+				<link rel="alternate" href="/feed">
+				<link rel="Canonical" href="/article#comments">
+				<link rel="canonical" href="/second">
+			</head>`,
+			pageUrl: "https://example.com/article?page=2",
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.CanonicalUrl != "https://example.com/article" {
+					t.Errorf("parseDocument() canonical = %q, want %q", document.CanonicalUrl, "https://example.com/article")
+				}
+			},
+		},
+		{
+			name: "language from html",
+			body: `<html lang=" de-CH "><head><meta http-equiv="Content-Language" content="fr"></head></html>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.Language != "de-CH" {
+					t.Errorf("parseDocument() language = %q, want %q", document.Language, "de-CH")
+				}
+			},
+		},
+		{
+			name: "language from http-equiv",
+			body: `<html><head><meta http-equiv="content-language" content="fr"></head></html>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.Language != "fr" {
+					t.Errorf("parseDocument() language = %q, want %q", document.Language, "fr")
+				}
+			},
+		},
+		{
+			name:        "charset from content type",
+			body:        "<title>Caf\xe9</title>",
+			contentType: "text/html; charset=iso-8859-1",
+			check: func(t *testing.T, document *ExtractedDocument) {
+				if document.Title != "Café" {
+					t.Errorf("parseDocument() title = %q, want %q", document.Title, "Café")
+				}
+			},
+		},
+		{
+			name: "boilerplate removed",
+			body: `<body>
+				<header>Site header</header>
+				<nav><a href="/">Home</a></nav>
+				<div role="navigation">Breadcrumbs</div>
+				<p>First   paragraph  of text.</p>
+				<script>var tracking = 1;</script>
+				<style>p { color: red }</style>
+				<div hidden>Hidden text</div>
+				<div aria-hidden="true">Decoration</div>
+				<ul><li><a href="/a">Link one</a></li><li><a href="/b">Link two</a></li></ul>
+				<div>Read the <a href="/more">docs</a> for everything else you may need.</div>
+				<aside>Related</aside>
+				<form><button>Subscribe</button></form>
+				<footer>Copyright</footer>
+			</body>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				want := "First paragraph of text.\nRead the docs for everything else you may need."
+				if document.Content != want {
+					t.Errorf("parseDocument() content = %q, want %q", document.Content, want)
+				}
+			},
+		},
+		{
+			name: "largest main or article wins",
+			body: `<body>
+				<p>Outside</p>
+				<article><p>Short teaser</p></article>
+				<main><h1>Title</h1><p>The longer main content of the page.</p></main>
+			</body>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				want := "Title\nThe longer main content of the page."
+				if document.Content != want {
+					t.Errorf("parseDocument() content = %q, want %q", document.Content, want)
+				}
+			},
+		},
+		{
+			name: "link heavy containers nested in content",
+			body: `<main>
+				<section><p>Body text that is long enough to outweigh the links.</p><ul><li><a href="/x">x</a></li></ul></section>
+				<section><ul><li><a href="/tag/one">Tag one</a></li><li><a href="/tag/two">Tag two</a></li></ul></section>
+			</main>`,
+			check: func(t *testing.T, document *ExtractedDocument) {
+				want := "Body text that is long enough to outweigh the links."
+				if document.Content != want {
+					t.Errorf("parseDocument() content = %q, want %q", document.Content, want)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pageUrl := test.pageUrl
+			if pageUrl == "" {
+				pageUrl = "https://example.com/"
+			}
+
+			document, err := parseDocument([]byte(test.body), test.contentType, pageUrl)
+			if err != nil {
+				t.Fatalf("parseDocument() error = %s", err)
+			}
+
+			test.check(t, document)
+		})
+	}
+}
+
+func TestParseDocumentTruncatesContent(t *testing.T) {
+	body := "<body><p>" + strings.Repeat("é", MaxDocumentContent) + "</p></body>"
+
+	document, err := parseDocument([]byte(body), "text/html; charset=utf-8", "https://example.com/")
+	if err != nil {
+		t.Fatalf("parseDocument() error = %s", err)
+	}
+
+	if len(document.Content) > MaxDocumentContent || !strings.HasPrefix(document.Content, "éé") || strings.ContainsRune(document.Content, '�') {
+		t.Errorf("parseDocument() content is %d bytes, want at most %d of valid UTF-8", len(document.Content), MaxDocumentContent)
+	}
+}
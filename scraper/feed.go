@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	SitemapFormatRSS  = "rss"
+	SitemapFormatAtom = "atom"
+)
+
+type rssItem struct {
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+}
+
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+var rssDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+}
+
+var atomDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+}
+
+// rssItemLink returns the URL and publication date of an RSS <item>, falling back to a
+// permalink guid when the item has no link.
+func rssItemLink(item rssItem) (location string, date string) {
+	location = strings.TrimSpace(item.Link)
+	if location == "" && strings.HasPrefix(item.GUID, "http") {
+		location = strings.TrimSpace(item.GUID)
+	}
+
+	return location, item.PubDate
+}
+
+func atomEntryLink(entry atomEntry) (location string, date string) {
+	for _, link := range entry.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			location = strings.TrimSpace(link.Href)
+			break
+		}
+	}
+
+	date = entry.Updated
+	if date == "" {
+		date = entry.Published
+	}
+
+	return location, date
+}
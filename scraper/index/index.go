@@ -0,0 +1,239 @@
+// Package index maintains the inverted search index in ClickHouse. Documents are split into
+// fields, tokenized, and stored as one posting per term, field and document.
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FieldURL         = "url"
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldHeadings    = "headings"
+	FieldContent     = "content"
+)
+
+// Fields lists every indexed field.
+var Fields = []string{FieldURL, FieldTitle, FieldDescription, FieldHeadings, FieldContent}
+
+// schema creates the index tables. Both use ReplacingMergeTree versioned by the crawl time of
+// the document, so writing a document again replaces its rows once parts are merged, and an older
+// crawl written late never wins over a newer one. Postings of terms that disappeared from a
+// document are not replaced by anything; readers drop postings whose version is not the one of
+// the document.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS index_postings (
+		term       String,
+		field      LowCardinality(String),
+		doc_id     UUID,
+		entity_id  UUID,
+		term_freq  UInt32,
+		positions  Array(UInt32),
+		version    DateTime64(3, 'UTC'),
+		indexed_at DateTime64(3, 'UTC')
+	)
+	ENGINE = ReplacingMergeTree(version)
+	ORDER BY (term, field, doc_id)`,
+	`CREATE TABLE IF NOT EXISTS index_documents (
+		doc_id        UUID,
+		entity_id     UUID,
+		upload_id     UUID,
+		url           String,
+		canonical_url String,
+		crawled_at    DateTime64(3, 'UTC'),
+		title         String,
+		description   String,
+		language      LowCardinality(String),
+		field_lengths Map(LowCardinality(String), UInt32),
+		indexed_at    DateTime64(3, 'UTC')
+	)
+	ENGINE = ReplacingMergeTree(crawled_at)
+	ORDER BY doc_id`,
+}
+
+// CreateSchema creates the index tables if they do not exist yet.
+func CreateSchema(ctx context.Context, db *sql.DB) error {
+	for _, statement := range schema {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("Failed to create index schema: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// DocumentKey identifies the indexed document of a canonical URL within an entity, so every
+// crawl of the URL replaces the previous one instead of adding to it.
+func DocumentKey(entityID uuid.UUID, canonicalURL string) uuid.UUID {
+	return uuid.NewSHA1(entityID, []byte(canonicalURL))
+}
+
+type Document struct {
+	// ID is DocumentKey(EntityID, CanonicalURL).
+	ID           uuid.UUID
+	EntityID     uuid.UUID
+	UploadID     uuid.UUID
+	URL          string
+	CanonicalURL string
+	Title        string
+	Description  string
+	Language     string
+
+	// When the page was fetched. An indexed document is only replaced by one crawled no earlier.
+	CrawledAt time.Time
+
+	// Text per field, see Fields.
+	Fields map[string]string
+}
+
+type Posting struct {
+	Term      string
+	Field     string
+	DocID     uuid.UUID
+	EntityID  uuid.UUID
+	Positions []uint32
+}
+
+// Postings tokenizes every field of doc and returns its postings together with the number of
+// tokens per field, which ranking needs for length normalization.
+func Postings(doc Document) ([]Posting, map[string]uint32) {
+	var postings []Posting
+	lengths := make(map[string]uint32, len(doc.Fields))
+
+	for _, field := range Fields {
+		tokens := Tokenize(doc.Fields[field])
+		if len(tokens) == 0 {
+			continue
+		}
+
+		lengths[field] = tokens[len(tokens)-1].Position + 1
+
+		byTerm := make(map[string][]uint32)
+		var terms []string
+		for _, token := range tokens {
+			if _, ok := byTerm[token.Term]; !ok {
+				terms = append(terms, token.Term)
+			}
+
+			byTerm[token.Term] = append(byTerm[token.Term], token.Position)
+		}
+
+		for _, term := range terms {
+			postings = append(postings, Posting{
+				Term:      term,
+				Field:     field,
+				DocID:     doc.ID,
+				EntityID:  doc.EntityID,
+				Positions: byTerm[term],
+			})
+		}
+	}
+
+	return postings, lengths
+}
+
+type Writer struct {
+	DB *sql.DB
+}
+
+// Write adds documents to the index, replacing earlier versions with the same ID. The crawl
+// time is the version of a document and of its postings, see schema.
+func (w *Writer) Write(ctx context.Context, documents []Document) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	indexedAt := time.Now().UTC()
+
+	lengths := make([]map[string]uint32, len(documents))
+	err := w.batch(ctx, "INSERT INTO index_postings (term, field, doc_id, entity_id, term_freq, positions, version, indexed_at)",
+		func(stmt *sql.Stmt) error {
+			for i, doc := range documents {
+				postings, fieldLengths := Postings(doc)
+				lengths[i] = fieldLengths
+
+				for _, posting := range postings {
+					_, err := stmt.ExecContext(ctx,
+						posting.Term,
+						posting.Field,
+						posting.DocID,
+						posting.EntityID,
+						uint32(len(posting.Positions)),
+						posting.Positions,
+						doc.CrawledAt.UTC(),
+						indexedAt,
+					)
+
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+
+	if err != nil {
+		return fmt.Errorf("Failed to write postings: %s", err)
+	}
+
+	err = w.batch(ctx, "INSERT INTO index_documents (doc_id, entity_id, upload_id, url, canonical_url, crawled_at, title, description, language, field_lengths, indexed_at)",
+		func(stmt *sql.Stmt) error {
+			for i, doc := range documents {
+				_, err := stmt.ExecContext(ctx,
+					doc.ID,
+					doc.EntityID,
+					doc.UploadID,
+					doc.URL,
+					doc.CanonicalURL,
+					doc.CrawledAt.UTC(),
+					doc.Title,
+					doc.Description,
+					doc.Language,
+					lengths[i],
+					indexedAt,
+				)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+	if err != nil {
+		return fmt.Errorf("Failed to write documents: %s", err)
+	}
+
+	return nil
+}
+
+// batch sends every row appended by fn as one ClickHouse insert.
+func (w *Writer) batch(ctx context.Context, query string, fn func(stmt *sql.Stmt) error) error {
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = fn(stmt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,35 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxTermLength drops tokens that are almost certainly not words (hashes, base64 blobs).
+const MaxTermLength = 64
+
+type Token struct {
+	Term     string
+	Position uint32
+}
+
+// Tokenize splits text into lowercase terms made of letters and digits. Positions count every
+// token, including dropped ones, so phrase distances stay intact.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var position uint32
+
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, field := range fields {
+		if len(field) <= MaxTermLength {
+			tokens = append(tokens, Token{Term: strings.ToLower(field), Position: position})
+		}
+
+		position++
+	}
+
+	return tokens
+}
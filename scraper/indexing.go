@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+	"github.com/immz4/mindex/scraper/index"
+)
+
+type IndexDocumentsArgs struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	Limit    int       `json:"limit"`
+}
+
+type IndexDocumentsRes struct {
+	Indexed int `json:"indexed"`
+}
+
+// IndexDocuments writes up to args.Limit documents of an entity that are new or changed since
+// they were last indexed into the ClickHouse inverted index. Documents are indexed under their
+// canonical URL, so a recrawled page replaces the document of the previous crawl.
+func (sa *ScraperActivities) IndexDocuments(ctx context.Context, args IndexDocumentsArgs) (*IndexDocumentsRes, error) {
+	var rows []model.Document
+	err := SELECT(Document.AllColumns).
+		FROM(Document).
+		WHERE(
+			Document.EntityID.EQ(UUID(args.EntityID)).
+				AND(Document.IndexedAt.IS_NULL().OR(Document.IndexedAt.LT(Document.UpdatedAt))),
+		).
+		ORDER_BY(Document.ID.ASC()).
+		LIMIT(int64(args.Limit)).
+		QueryContext(ctx, sa.PGClient, &rows)
+
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("Failed to list unindexed documents: %s", err)
+	}
+
+	if len(rows) == 0 {
+		return &IndexDocumentsRes{}, nil
+	}
+
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, args.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]index.Document, 0, len(rows))
+	for _, row := range rows {
+		var headings []DocumentHeading
+		err = json.Unmarshal([]byte(row.Headings), &headings)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse headings of document %s: %s", row.ID, err)
+		}
+
+		headingTexts := make([]string, 0, len(headings))
+		for _, heading := range headings {
+			headingTexts = append(headingTexts, heading.Text)
+		}
+
+		canonicalUrl, err := normalizer.Normalize(row.URL)
+		if err != nil {
+			canonicalUrl = row.URL
+		}
+
+		documents = append(documents, index.Document{
+			ID:           index.DocumentKey(row.EntityID, canonicalUrl),
+			EntityID:     row.EntityID,
+			UploadID:     row.UploadID,
+			URL:          row.URL,
+			CanonicalURL: canonicalUrl,
+			Title:        row.Title,
+			Description:  valueOrEmpty(row.Description),
+			Language:     valueOrEmpty(row.Language),
+			CrawledAt:    row.CreatedAt,
+			Fields: map[string]string{
+				index.FieldURL:         row.URL,
+				index.FieldTitle:       row.Title,
+				index.FieldDescription: valueOrEmpty(row.Description),
+				index.FieldHeadings:    strings.Join(headingTexts, "\n"),
+				index.FieldContent:     row.Content,
+			},
+		})
+	}
+
+	writer := &index.Writer{DB: sa.CHClient}
+	err = writer.Write(ctx, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	// A document extracted again while it was being indexed keeps a newer updated_at than the
+	// version recorded here and is picked up by the next call.
+	err = InTx(ctx, sa.PGClient, func(repo *Repository) error {
+		for _, row := range rows {
+			err := repo.MarkDocumentIndexed(ctx, row.ID, row.UpdatedAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexDocumentsRes{Indexed: len(rows)}, nil
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/google/uuid"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+	"github.com/immz4/mindex/scraper/urlnorm"
+)
+
+const (
+	UrlsetSourceSitemap = "sitemap"
+	UrlsetSourceLink    = "link"
+)
+
+// linkSource is what discovered links are attributed to and enqueued under.
+type linkSource struct {
+	EntityID   uuid.UUID
+	UploadID   uuid.UUID
+	PageID     uuid.UUID
+	EntityHost string
+	RobotsID   uuid.UUID
+	Depth      int32
+	Normalizer *urlnorm.Normalizer
+}
+
+func (sa *ScraperActivities) getLinkSource(ctx context.Context, page model.Page) (*linkSource, error) {
+	var entity model.Entity
+	err := SELECT(Entity.Host).
+		FROM(Entity).
+		WHERE(Entity.ID.EQ(UUID(page.EntityID))).
+		QueryContext(ctx, sa.PGClient, &entity)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get entity: %s", err)
+	}
+
+	normalizer, err := EntityNormalizer(ctx, sa.PGClient, page.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var urlset model.SitemapUrlset
+	err = SELECT(SitemapUrlset.RobotsID, SitemapUrlset.Depth).
+		FROM(SitemapUrlset).
+		WHERE(SitemapUrlset.ID.EQ(UUID(page.UrlsetID))).
+		QueryContext(ctx, sa.PGClient, &urlset)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get urlset: %s", err)
+	}
+
+	return &linkSource{
+		EntityID:   page.EntityID,
+		UploadID:   page.UploadID,
+		PageID:     page.ID,
+		EntityHost: entity.Host,
+		RobotsID:   urlset.RobotsID,
+		Depth:      urlset.Depth,
+		Normalizer: normalizer,
+	}, nil
+}
+
+// linkRows turns the links of a page into link graph edges.
+func (s *linkSource) linkRows(links []DocumentLink) []model.Link {
+	rows := make([]model.Link, 0, len(links))
+	for _, link := range links {
+		canonicalUrl, err := s.Normalizer.Normalize(link.Url)
+		if err != nil {
+			continue
+		}
+
+		rows = append(rows, model.Link{
+			EntityID:     s.EntityID,
+			UploadID:     s.UploadID,
+			PageID:       s.PageID,
+			URL:          link.Url,
+			CanonicalURL: canonicalUrl,
+			AnchorText:   optionalString(link.Text),
+			Rel:          optionalString(link.Rel),
+			Nofollow:     link.NoFollow,
+		})
+	}
+
+	return rows
+}
+
+// discoveredUrlset returns the followable links pointing at the same entity as urlset rows one
+// hop deeper than the page they were found on. Nothing is returned past MaxLinkDepth.
+func (s *linkSource) discoveredUrlset(links []DocumentLink) []model.SitemapUrlset {
+	if s.Depth+1 > MaxLinkDepth {
+		return nil
+	}
+
+	var rows []model.SitemapUrlset
+	for _, link := range links {
+		if link.NoFollow {
+			continue
+		}
+
+		_, host, err := NormalizeEntityURL(link.Url)
+		if err != nil || host != s.EntityHost {
+			continue
+		}
+
+		canonicalUrl, err := s.Normalizer.Normalize(link.Url)
+		if err != nil {
+			continue
+		}
+
+		rows = append(rows, model.SitemapUrlset{
+			EntityID:     s.EntityID,
+			UploadID:     s.UploadID,
+			RobotsID:     s.RobotsID,
+			URL:          link.Url,
+			CanonicalURL: canonicalUrl,
+			Source:       UrlsetSourceLink,
+			Depth:        s.Depth + 1,
+		})
+	}
+
+	return rows
+}
@@ -0,0 +1,332 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// migrationLockKey serializes concurrent migrators through a Postgres advisory lock.
+const migrationLockKey = 7_311_214_025
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations ordered by version. Every version needs both
+// an up and a down file.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list migrations: %s", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("Unexpected migration file %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse migration version %s: %s", entry.Name(), err)
+		}
+
+		data, err := files.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read migration %s: %s", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("Migration %d has conflicting names %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migration %d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations.
+// Each migration runs in its own transaction together with its bookkeeping row.
+type Migrator struct {
+	DB *sql.DB
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    bigint PRIMARY KEY,
+			name       text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create schema_migrations: %s", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, db interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}) (map[int64]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read schema_migrations: %s", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read schema_migrations: %s", err)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and when it was applied, if at all.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Baseline adopts a database created before migrations were tracked: when schema_migrations is
+// empty but the tables of the first migration exist, that migration is recorded as applied
+// without running it. Up then brings the database forward like any other, including the fixups
+// later migrations do for data written by older versions. Reports whether it adopted the database.
+func (m *Migrator) Baseline(ctx context.Context) (bool, error) {
+	migrations, err := Load()
+	if err != nil {
+		return false, err
+	}
+
+	if len(migrations) == 0 {
+		return false, nil
+	}
+
+	err = m.ensureTable(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("Failed to start DB transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey)
+	if err != nil {
+		return false, fmt.Errorf("Failed to take migration lock: %s", err)
+	}
+
+	var tracked, legacy bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM schema_migrations), to_regclass('entity') IS NOT NULL`,
+	).Scan(&tracked, &legacy)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to inspect database: %s", err)
+	}
+
+	if tracked || !legacy {
+		return false, nil
+	}
+
+	initial := migrations[0]
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, initial.Version, initial.Name)
+	if err != nil {
+		return false, fmt.Errorf("Failed to record migration %d_%s: %s", initial.Version, initial.Name, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("Failed to commit baseline: %s", err)
+	}
+
+	return true, nil
+}
+
+// Up applies every pending migration in order and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		ran, err := m.run(ctx, migration, true)
+		if err != nil {
+			return done, err
+		}
+
+		if ran {
+			done = append(done, migration)
+		}
+	}
+
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		ran, err := m.run(ctx, migrations[i], false)
+		if err != nil {
+			return done, err
+		}
+
+		if ran {
+			done = append(done, migrations[i])
+		}
+	}
+
+	return done, nil
+}
+
+// run applies or reverts a single migration. It reports false when there was nothing to do,
+// which is checked under the advisory lock so concurrent migrators never apply the same version twice.
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) (bool, error) {
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("Failed to start DB transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey)
+	if err != nil {
+		return false, fmt.Errorf("Failed to take migration lock: %s", err)
+	}
+
+	applied, err := m.applied(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	_, isApplied := applied[migration.Version]
+	if isApplied == up {
+		return false, nil
+	}
+
+	script := migration.Down
+	bookkeeping := `DELETE FROM schema_migrations WHERE version = $1`
+	args := []any{migration.Version}
+
+	if up {
+		script = migration.Up
+		bookkeeping = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+		args = append(args, migration.Name)
+	}
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return false, fmt.Errorf("Failed to run migration %d_%s: %s", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, bookkeeping, args...)
+	if err != nil {
+		return false, fmt.Errorf("Failed to record migration %d_%s: %s", migration.Version, migration.Name, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("Failed to commit migration %d_%s: %s", migration.Version, migration.Name, err)
+	}
+
+	return true, nil
+}
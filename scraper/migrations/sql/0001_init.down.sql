@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS page;
+DROP TABLE IF EXISTS page_body;
+DROP TABLE IF EXISTS disallowed_url;
+DROP TABLE IF EXISTS sitemap_urlset;
+DROP TABLE IF EXISTS sitemap_index;
+DROP TABLE IF EXISTS robots;
+DROP TABLE IF EXISTS entity;
@@ -0,0 +1,97 @@
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+CREATE TABLE entity (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       text NOT NULL,
+    url        text NOT NULL,
+    enabled    boolean NOT NULL DEFAULT true,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE robots (
+    id                 uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id          uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id          uuid NOT NULL,
+    data               text NOT NULL,
+    status_code        integer NOT NULL,
+    policy             text NOT NULL,
+    etag               text,
+    http_last_modified text,
+    scraped            boolean NOT NULL DEFAULT false,
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    updated_at         timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX robots_entity_created_at_idx ON robots (entity_id, created_at DESC);
+
+CREATE TABLE sitemap_index (
+    id                 uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id          uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id          uuid NOT NULL,
+    robots_id          uuid NOT NULL REFERENCES robots (id) ON DELETE CASCADE,
+    origin_id          uuid REFERENCES sitemap_index (id) ON DELETE CASCADE,
+    url                text NOT NULL,
+    last_modified      timestamptz NOT NULL,
+    etag               text,
+    http_last_modified text,
+    scraped            boolean NOT NULL DEFAULT false,
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    updated_at         timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX sitemap_index_origin_idx ON sitemap_index (upload_id, entity_id, origin_id, id) WHERE NOT scraped;
+CREATE INDEX sitemap_index_entity_url_idx ON sitemap_index (entity_id, url, updated_at DESC);
+
+CREATE TABLE sitemap_urlset (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id     uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id     uuid NOT NULL,
+    robots_id     uuid NOT NULL REFERENCES robots (id) ON DELETE CASCADE,
+    origin_id     uuid REFERENCES sitemap_index (id) ON DELETE CASCADE,
+    url           text NOT NULL,
+    last_modified timestamptz NOT NULL,
+    change_freq   text,
+    scraped       boolean NOT NULL DEFAULT false,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX sitemap_urlset_unscraped_idx ON sitemap_urlset (entity_id, upload_id, id) WHERE NOT scraped;
+
+CREATE TABLE disallowed_url (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id  uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id  uuid NOT NULL,
+    robots_id  uuid NOT NULL REFERENCES robots (id) ON DELETE CASCADE,
+    url        text NOT NULL,
+    reason     text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX disallowed_url_entity_idx ON disallowed_url (entity_id, upload_id);
+
+CREATE TABLE page_body (
+    hash       text PRIMARY KEY,
+    data       bytea NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE page (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id    uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id    uuid NOT NULL,
+    urlset_id    uuid NOT NULL REFERENCES sitemap_urlset (id) ON DELETE CASCADE,
+    url          text NOT NULL,
+    final_url    text NOT NULL,
+    status_code  integer NOT NULL,
+    headers      jsonb NOT NULL,
+    content_type text,
+    body_ref     text REFERENCES page_body (hash),
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX page_entity_upload_idx ON page (entity_id, upload_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS entity_host_key;
+
+ALTER TABLE entity DROP COLUMN IF EXISTS host;
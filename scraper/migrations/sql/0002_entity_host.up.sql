@@ -0,0 +1,8 @@
+-- Entities are deduplicated by host, ignoring a leading "www.".
+ALTER TABLE entity ADD COLUMN host text;
+
+UPDATE entity SET host = lower(substring(url FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:www\.)?([^/:?#]+)'));
+
+ALTER TABLE entity ALTER COLUMN host SET NOT NULL;
+
+CREATE UNIQUE INDEX entity_host_key ON entity (host);
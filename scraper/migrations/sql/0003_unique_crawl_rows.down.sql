@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS sitemap_urlset_entity_upload_url_key;
+DROP INDEX IF EXISTS sitemap_index_entity_upload_url_key;
+DROP INDEX IF EXISTS robots_entity_upload_key;
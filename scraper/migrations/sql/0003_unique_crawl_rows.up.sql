@@ -0,0 +1,41 @@
+-- Collapse rows duplicated by retried saves onto the oldest one (preferring scraped rows),
+-- pointing everything that referenced a duplicate at the row that is kept.
+
+CREATE TEMPORARY TABLE robots_duplicate ON COMMIT DROP AS
+SELECT id, keep_id FROM (
+    SELECT id, first_value(id) OVER (PARTITION BY entity_id, upload_id ORDER BY created_at, id) AS keep_id
+    FROM robots
+) ranked
+WHERE id <> keep_id;
+
+UPDATE sitemap_index SET robots_id = d.keep_id FROM robots_duplicate d WHERE sitemap_index.robots_id = d.id;
+UPDATE sitemap_urlset SET robots_id = d.keep_id FROM robots_duplicate d WHERE sitemap_urlset.robots_id = d.id;
+UPDATE disallowed_url SET robots_id = d.keep_id FROM robots_duplicate d WHERE disallowed_url.robots_id = d.id;
+DELETE FROM robots WHERE id IN (SELECT id FROM robots_duplicate);
+
+CREATE TEMPORARY TABLE sitemap_index_duplicate ON COMMIT DROP AS
+SELECT id, keep_id FROM (
+    SELECT id, first_value(id) OVER (PARTITION BY entity_id, upload_id, url ORDER BY scraped DESC, created_at, id) AS keep_id
+    FROM sitemap_index
+) ranked
+WHERE id <> keep_id;
+
+UPDATE sitemap_index SET origin_id = d.keep_id FROM sitemap_index_duplicate d WHERE sitemap_index.origin_id = d.id;
+UPDATE sitemap_urlset SET origin_id = d.keep_id FROM sitemap_index_duplicate d WHERE sitemap_urlset.origin_id = d.id;
+-- A duplicate may have ended up as its own origin after the update above.
+UPDATE sitemap_index SET origin_id = NULL WHERE origin_id = id;
+DELETE FROM sitemap_index WHERE id IN (SELECT id FROM sitemap_index_duplicate);
+
+CREATE TEMPORARY TABLE sitemap_urlset_duplicate ON COMMIT DROP AS
+SELECT id, keep_id FROM (
+    SELECT id, first_value(id) OVER (PARTITION BY entity_id, upload_id, url ORDER BY scraped DESC, created_at, id) AS keep_id
+    FROM sitemap_urlset
+) ranked
+WHERE id <> keep_id;
+
+UPDATE page SET urlset_id = d.keep_id FROM sitemap_urlset_duplicate d WHERE page.urlset_id = d.id;
+DELETE FROM sitemap_urlset WHERE id IN (SELECT id FROM sitemap_urlset_duplicate);
+
+CREATE UNIQUE INDEX robots_entity_upload_key ON robots (entity_id, upload_id);
+CREATE UNIQUE INDEX sitemap_index_entity_upload_url_key ON sitemap_index (entity_id, upload_id, url);
+CREATE UNIQUE INDEX sitemap_urlset_entity_upload_url_key ON sitemap_urlset (entity_id, upload_id, url);
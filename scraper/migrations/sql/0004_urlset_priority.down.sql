@@ -0,0 +1,5 @@
+ALTER TABLE sitemap_urlset DROP CONSTRAINT IF EXISTS sitemap_urlset_change_freq_check;
+
+ALTER TABLE sitemap_urlset DROP CONSTRAINT IF EXISTS sitemap_urlset_priority_check;
+
+ALTER TABLE sitemap_urlset DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,7 @@
+ALTER TABLE sitemap_urlset ADD COLUMN priority real;
+
+ALTER TABLE sitemap_urlset ADD CONSTRAINT sitemap_urlset_priority_check
+    CHECK (priority BETWEEN 0 AND 1);
+
+ALTER TABLE sitemap_urlset ADD CONSTRAINT sitemap_urlset_change_freq_check
+    CHECK (change_freq IN ('always', 'hourly', 'daily', 'weekly', 'monthly', 'yearly', 'never'));
@@ -0,0 +1,5 @@
+UPDATE sitemap_index SET last_modified = '0001-01-01 00:00:00+00' WHERE last_modified IS NULL;
+UPDATE sitemap_urlset SET last_modified = '0001-01-01 00:00:00+00' WHERE last_modified IS NULL;
+
+ALTER TABLE sitemap_index ALTER COLUMN last_modified SET NOT NULL;
+ALTER TABLE sitemap_urlset ALTER COLUMN last_modified SET NOT NULL;
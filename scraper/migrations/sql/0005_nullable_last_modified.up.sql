@@ -0,0 +1,6 @@
+-- A missing <lastmod> used to be stored as the zero time (year 1).
+ALTER TABLE sitemap_index ALTER COLUMN last_modified DROP NOT NULL;
+ALTER TABLE sitemap_urlset ALTER COLUMN last_modified DROP NOT NULL;
+
+UPDATE sitemap_index SET last_modified = NULL WHERE last_modified < '0002-01-01';
+UPDATE sitemap_urlset SET last_modified = NULL WHERE last_modified < '0002-01-01';
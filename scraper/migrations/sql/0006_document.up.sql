@@ -0,0 +1,19 @@
+CREATE TABLE document (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id     uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id     uuid NOT NULL,
+    page_id       uuid NOT NULL REFERENCES page (id) ON DELETE CASCADE,
+    url           text NOT NULL,
+    title         text NOT NULL,
+    description   text,
+    headings      jsonb NOT NULL,
+    language      text,
+    canonical_url text,
+    content       text NOT NULL,
+    links         jsonb NOT NULL,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX document_page_idx ON document (page_id);
+CREATE INDEX document_entity_upload_idx ON document (entity_id, upload_id);
@@ -0,0 +1,7 @@
+DELETE FROM sitemap_urlset WHERE source = 'link';
+
+ALTER TABLE sitemap_urlset DROP CONSTRAINT IF EXISTS sitemap_urlset_source_check;
+ALTER TABLE sitemap_urlset DROP COLUMN IF EXISTS depth;
+ALTER TABLE sitemap_urlset DROP COLUMN IF EXISTS source;
+
+DROP TABLE IF EXISTS link;
@@ -0,0 +1,22 @@
+CREATE TABLE link (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    entity_id   uuid NOT NULL REFERENCES entity (id) ON DELETE CASCADE,
+    upload_id   uuid NOT NULL,
+    page_id     uuid NOT NULL REFERENCES page (id) ON DELETE CASCADE,
+    url         text NOT NULL,
+    anchor_text text,
+    rel         text,
+    nofollow    boolean NOT NULL DEFAULT false,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX link_page_url_idx ON link (page_id, url);
+CREATE INDEX link_entity_upload_url_idx ON link (entity_id, upload_id, url);
+
+-- Urlset rows are also discovered by following links; depth counts the hops from a sitemap entry.
+ALTER TABLE sitemap_urlset ADD COLUMN source text NOT NULL DEFAULT 'sitemap';
+ALTER TABLE sitemap_urlset ADD COLUMN depth integer NOT NULL DEFAULT 0;
+
+ALTER TABLE sitemap_urlset ADD CONSTRAINT sitemap_urlset_source_check
+    CHECK (source IN ('sitemap', 'link'));
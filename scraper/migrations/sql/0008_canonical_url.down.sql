@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS link_entity_upload_canonical_url_idx;
+DROP INDEX IF EXISTS link_page_canonical_url_idx;
+DROP INDEX IF EXISTS sitemap_urlset_entity_upload_canonical_url_key;
+DROP INDEX IF EXISTS sitemap_index_entity_upload_canonical_url_key;
+
+CREATE INDEX link_entity_upload_url_idx ON link (entity_id, upload_id, url);
+CREATE UNIQUE INDEX link_page_url_idx ON link (page_id, url);
+CREATE UNIQUE INDEX sitemap_urlset_entity_upload_url_key ON sitemap_urlset (entity_id, upload_id, url);
+CREATE UNIQUE INDEX sitemap_index_entity_upload_url_key ON sitemap_index (entity_id, upload_id, url);
+
+ALTER TABLE link DROP COLUMN IF EXISTS canonical_url;
+ALTER TABLE sitemap_urlset DROP COLUMN IF EXISTS canonical_url;
+ALTER TABLE sitemap_index DROP COLUMN IF EXISTS canonical_url;
+
+ALTER TABLE entity DROP COLUMN IF EXISTS url_rules;
@@ -0,0 +1,25 @@
+-- Per-entity URL normalization rules, see urlnorm.Rules.
+ALTER TABLE entity ADD COLUMN url_rules jsonb;
+
+-- Rows saved before normalization existed keep their raw URL as canonical form.
+ALTER TABLE sitemap_index ADD COLUMN canonical_url text;
+UPDATE sitemap_index SET canonical_url = url;
+ALTER TABLE sitemap_index ALTER COLUMN canonical_url SET NOT NULL;
+
+ALTER TABLE sitemap_urlset ADD COLUMN canonical_url text;
+UPDATE sitemap_urlset SET canonical_url = url;
+ALTER TABLE sitemap_urlset ALTER COLUMN canonical_url SET NOT NULL;
+
+ALTER TABLE link ADD COLUMN canonical_url text;
+UPDATE link SET canonical_url = url;
+ALTER TABLE link ALTER COLUMN canonical_url SET NOT NULL;
+
+DROP INDEX sitemap_index_entity_upload_url_key;
+DROP INDEX sitemap_urlset_entity_upload_url_key;
+DROP INDEX link_page_url_idx;
+DROP INDEX link_entity_upload_url_idx;
+
+CREATE UNIQUE INDEX sitemap_index_entity_upload_canonical_url_key ON sitemap_index (entity_id, upload_id, canonical_url);
+CREATE UNIQUE INDEX sitemap_urlset_entity_upload_canonical_url_key ON sitemap_urlset (entity_id, upload_id, canonical_url);
+CREATE UNIQUE INDEX link_page_canonical_url_idx ON link (page_id, canonical_url);
+CREATE INDEX link_entity_upload_canonical_url_idx ON link (entity_id, upload_id, canonical_url);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS document_unindexed_idx;
+
+ALTER TABLE document DROP COLUMN IF EXISTS indexed_at;
@@ -0,0 +1,5 @@
+-- updated_at of the document version that is in the search index.
+ALTER TABLE document ADD COLUMN indexed_at timestamptz;
+
+CREATE INDEX document_unindexed_idx ON document (entity_id, id)
+    WHERE indexed_at IS NULL OR indexed_at < updated_at;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS disallowed_url_entity_upload_canonical_url_key;
+
+ALTER TABLE disallowed_url DROP COLUMN IF EXISTS canonical_url;
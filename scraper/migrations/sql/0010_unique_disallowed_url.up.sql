@@ -0,0 +1,14 @@
+ALTER TABLE disallowed_url ADD COLUMN canonical_url text;
+UPDATE disallowed_url SET canonical_url = url;
+ALTER TABLE disallowed_url ALTER COLUMN canonical_url SET NOT NULL;
+
+-- Retried checks used to insert the same URL again; keep the oldest row.
+DELETE FROM disallowed_url WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY entity_id, upload_id, canonical_url ORDER BY created_at, id) AS n
+        FROM disallowed_url
+    ) ranked
+    WHERE n > 1
+);
+
+CREATE UNIQUE INDEX disallowed_url_entity_upload_canonical_url_key ON disallowed_url (entity_id, upload_id, canonical_url);
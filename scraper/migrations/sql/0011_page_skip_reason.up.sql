@@ -0,0 +1,2 @@
+-- Why a page was recorded without a body, e.g. 'too_large'.
+ALTER TABLE page ADD COLUMN skip_reason text;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS page_urlset_idx;
@@ -0,0 +1,10 @@
+-- Retried fetches used to insert the same page again; keep the latest row.
+DELETE FROM page WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY urlset_id ORDER BY created_at DESC, id DESC) AS n
+        FROM page
+    ) ranked
+    WHERE n > 1
+);
+
+CREATE UNIQUE INDEX page_urlset_idx ON page (urlset_id);
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/immz4/mindex/scraper"
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	"github.com/immz4/mindex/scraper/config"
+	"github.com/immz4/mindex/scraper/urlnorm"
+	"go.temporal.io/sdk/client"
+)
+
+func runEntity(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("entity needs one of add, list, update, remove or import")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgDb, err := cfg.OpenPostgres()
+	if err != nil {
+		return err
+	}
+	defer pgDb.Close()
+
+	flags := flag.NewFlagSet("entity "+args[0], flag.ExitOnError)
+
+	switch args[0] {
+	case "add":
+		name := flags.String("name", "", "display name, defaults to the host")
+		url := flags.String("url", "", "site URL or bare domain")
+		crawl := flags.Bool("crawl", false, "start the first crawl right away")
+		flags.Parse(args[1:])
+
+		if *url == "" {
+			return errors.New("-url is required")
+		}
+
+		added, err := scraper.AddEntities(ctx, pgDb, []scraper.EntityInput{{Name: *name, Url: *url}})
+		if err != nil {
+			return err
+		}
+
+		if len(added) == 0 {
+			return fmt.Errorf("An entity for %s already exists", *url)
+		}
+
+		printEntities(added)
+
+		return startCrawls(ctx, cfg, added, *crawl)
+	case "list":
+		flags.Parse(args[1:])
+
+		entities, err := scraper.ListEntities(ctx, pgDb)
+		if err != nil {
+			return err
+		}
+
+		printEntities(entities)
+
+		return nil
+	case "update":
+		id := flags.String("id", "", "entity ID")
+		name := flags.String("name", "", "new display name")
+		url := flags.String("url", "", "new site URL or bare domain")
+		enabled := flags.Bool("enabled", true, "whether scheduled crawls run")
+		stripParams := flags.String("strip-params", "", "comma separated query parameters dropped from URLs, a trailing * matches a prefix")
+		keepParams := flags.String("keep-params", "", "comma separated query parameters kept even if stripped by default")
+		flags.Parse(args[1:])
+
+		entityID, err := uuid.Parse(*id)
+		if err != nil {
+			return fmt.Errorf("Invalid -id %q: %s", *id, err)
+		}
+
+		update := scraper.UpdateEntityArgs{ID: entityID}
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name":
+				update.Name = name
+			case "url":
+				update.Url = url
+			case "enabled":
+				update.Enabled = enabled
+			case "strip-params", "keep-params":
+				// Either flag replaces the whole rule set.
+				update.URLRules = &urlnorm.Rules{
+					StripParams: splitList(*stripParams),
+					KeepParams:  splitList(*keepParams),
+				}
+			}
+		})
+
+		entity, err := scraper.UpdateEntity(ctx, pgDb, update)
+		if err != nil {
+			return err
+		}
+
+		printEntities([]model.Entity{*entity})
+
+		return nil
+	case "remove":
+		id := flags.String("id", "", "entity ID")
+		flags.Parse(args[1:])
+
+		entityID, err := uuid.Parse(*id)
+		if err != nil {
+			return fmt.Errorf("Invalid -id %q: %s", *id, err)
+		}
+
+		err = scraper.RemoveEntity(ctx, pgDb, entityID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %s\n", entityID)
+
+		return nil
+	case "import":
+		format := flags.String("format", scraper.EntityListCSV, "list format, csv or tranco")
+		limit := flags.Int("limit", 0, "only import the first n valid entries, 0 imports everything")
+		crawl := flags.Bool("crawl", false, "start the first crawl for every new entity")
+		flags.Parse(args[1:])
+
+		if flags.NArg() != 1 {
+			return errors.New("import needs exactly one file, use - for stdin")
+		}
+
+		var reader io.Reader = os.Stdin
+		if flags.Arg(0) != "-" {
+			file, err := os.Open(flags.Arg(0))
+			if err != nil {
+				return fmt.Errorf("Failed to open %s: %s", flags.Arg(0), err)
+			}
+			defer file.Close()
+
+			reader = file
+		}
+
+		inputs, invalid, err := scraper.ParseEntityList(reader, *format)
+		if err != nil {
+			return err
+		}
+
+		for _, lineErr := range invalid {
+			log.Println("Skipping invalid entry", lineErr)
+		}
+
+		if *limit > 0 && len(inputs) > *limit {
+			inputs = inputs[:*limit]
+		}
+
+		added, err := scraper.AddEntities(ctx, pgDb, inputs)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported %d entities, %d already existed, %d invalid\n", len(added), len(inputs)-len(added), len(invalid))
+
+		return startCrawls(ctx, cfg, added, *crawl)
+	default:
+		return fmt.Errorf("Unknown entity command %s", args[0])
+	}
+}
+
+func startCrawls(ctx context.Context, cfg *config.Config, entities []model.Entity, crawl bool) error {
+	if !crawl || len(entities) == 0 {
+		return nil
+	}
+
+	c, err := client.Dial(cfg.TemporalOptions())
+	if err != nil {
+		return fmt.Errorf("Unable to create Temporal client: %s", err)
+	}
+	defer c.Close()
+
+	for _, entity := range entities {
+		err = scraper.StartEntityCrawl(ctx, c, entity, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Started %d crawls\n", len(entities))
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func printEntities(entities []model.Entity) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tURL\tENABLED")
+	for _, entity := range entities {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%t\n", entity.ID, entity.Name, entity.URL, entity.Enabled)
+	}
+
+	writer.Flush()
+}
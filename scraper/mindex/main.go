@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/immz4/mindex/scraper/config"
+)
+
+const usage = `Usage: mindex [-config path] <command> [arguments]
+
+Commands:
+  migrate up                apply every pending migration and create the ClickHouse index tables
+  migrate down [-steps n]   revert the last n migrations (default 1)
+  migrate status            list migrations and when they were applied
+
+  entity add -url u [-name n] [-crawl]
+  entity list
+  entity update -id id [-name n] [-url u] [-enabled=false]
+                [-strip-params p,...] [-keep-params p,...]
+  entity remove -id id
+  entity import [-format csv|tranco] [-limit n] [-crawl] file
+
+  search [-limit n] query   rank indexed documents, words prefixed with - are excluded
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	configPath := flag.String("config", "", "path to the YAML config, defaults to $"+config.PathEnv)
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalln("Unable to load config", err)
+	}
+
+	switch flag.Arg(0) {
+	case "migrate":
+		err = runMigrate(cfg, flag.Args()[1:])
+	case "entity":
+		err = runEntity(cfg, flag.Args()[1:])
+	case "search":
+		err = runSearch(cfg, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/immz4/mindex/scraper/config"
+	"github.com/immz4/mindex/scraper/index"
+	"github.com/immz4/mindex/scraper/migrations"
+)
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate needs one of up, down or status")
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	flags.Parse(args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgDb, err := cfg.OpenPostgres()
+	if err != nil {
+		return err
+	}
+	defer pgDb.Close()
+
+	migrator := &migrations.Migrator{DB: pgDb}
+
+	switch args[0] {
+	case "up":
+		adopted, err := migrator.Baseline(ctx)
+		if err != nil {
+			return err
+		}
+
+		if adopted {
+			fmt.Println("Adopted existing database at its initial schema")
+		}
+
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+
+		chDb := cfg.OpenClickHouse()
+		defer chDb.Close()
+
+		err = index.CreateSchema(ctx, chDb)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("ClickHouse index tables are in place")
+
+		return nil
+	case "down":
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("Unknown migrate command %s", args[0])
+	}
+}
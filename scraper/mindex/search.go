@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/immz4/mindex/scraper/config"
+	"github.com/immz4/mindex/scraper/search"
+)
+
+func runSearch(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	limit := flags.Int("limit", search.DefaultLimit, "number of results")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return errors.New("search needs a query")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	chDb := cfg.OpenClickHouse()
+	defer chDb.Close()
+
+	engine := search.NewEngine(&search.ClickHouseStore{DB: chDb})
+
+	results, err := engine.Search(ctx, search.ParseQuery(strings.Join(flags.Args(), " ")), *limit)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SCORE\tTITLE\tURL")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%.3f\t%s\t%s\n", result.Score, result.Title, result.URL)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,208 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+)
+
+const (
+	PageTooLargeErrorType = "PageTooLarge"
+
+	PageSkipTooLarge = "too_large"
+)
+
+type ListUnscrapedUrlsetArgs struct {
+	EntityID uuid.UUID  `json:"entity_id"`
+	UploadID *uuid.UUID `json:"upload_id,omitempty"`
+	AfterID  *uuid.UUID `json:"after_id,omitempty"`
+	Limit    int        `json:"limit"`
+}
+
+type UrlsetEntry struct {
+	ID       uuid.UUID `json:"id"`
+	UploadID uuid.UUID `json:"upload_id"`
+	Url      string    `json:"url"`
+}
+
+func (sa *ScraperActivities) ListUnscrapedUrlset(ctx context.Context, args ListUnscrapedUrlsetArgs) ([]UrlsetEntry, error) {
+	condition := SitemapUrlset.EntityID.EQ(UUID(args.EntityID)).
+		AND(SitemapUrlset.Scraped.IS_FALSE())
+
+	if args.UploadID != nil {
+		condition = condition.AND(SitemapUrlset.UploadID.EQ(UUID(*args.UploadID)))
+	}
+
+	if args.AfterID != nil {
+		condition = condition.AND(SitemapUrlset.ID.GT(UUID(*args.AfterID)))
+	}
+
+	var rows []model.SitemapUrlset
+	err := SELECT(SitemapUrlset.ID, SitemapUrlset.UploadID, SitemapUrlset.URL).
+		FROM(SitemapUrlset).
+		WHERE(condition).
+		ORDER_BY(SitemapUrlset.ID.ASC()).
+		LIMIT(int64(args.Limit)).
+		QueryContext(ctx, sa.PGClient, &rows)
+
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("Failed to list unscraped urlset: %s", err)
+	}
+
+	entries := make([]UrlsetEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, UrlsetEntry{
+			ID:       row.ID,
+			UploadID: row.UploadID,
+			Url:      row.URL,
+		})
+	}
+
+	return entries, nil
+}
+
+type FetchPageArgs struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	UploadID uuid.UUID `json:"upload_id"`
+	UrlsetID uuid.UUID `json:"urlset_id"`
+	Url      string    `json:"url"`
+}
+
+type FetchPageRes struct {
+	PageID     *string `json:"page_id,omitempty"`
+	StatusCode int     `json:"status_code"`
+	Disallowed bool    `json:"disallowed"`
+}
+
+// FetchPage downloads a single urlset entry and stores the response together with a
+// content-addressed reference to its body. The urlset row is flipped to scraped in the same transaction.
+// Bodies over MaxPageSize are not stored: the page is recorded as skipped and the activity fails
+// with a non-retryable PageTooLargeErrorType, since fetching it again gives the same result.
+func (sa *ScraperActivities) FetchPage(ctx context.Context, args FetchPageArgs) (*FetchPageRes, error) {
+	allowed, err := sa.CheckAllowed(ctx, CheckAllowedArgs{
+		UploadID: args.UploadID,
+		EntityID: args.EntityID,
+		Url:      args.Url,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check robots.txt rules: %s", err)
+	}
+
+	if !allowed {
+		err = NewRepository(sa.PGClient).MarkUrlsetScraped(ctx, args.UrlsetID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FetchPageRes{Disallowed: true}, nil
+	}
+
+	err = sa.waitForHost(ctx, args.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sa.HTTPClient.R().
+		SetHeader("User-Agent", sa.UserAgent).
+		SetHeader("Accept", "text/html, application/xhtml+xml;q=0.9, */*;q=0.5").
+		SetHeader("Accept-Encoding", "gzip, deflate, br, zstd").
+		SetHeader("Set-Fetch-Dest", "document").
+		SetHeader("Set-Fetch-Mode", "navigate").
+		SetHeader("Set-Fetch-User", "?1").
+		SetDoNotParseResponse(true).
+		Get(args.Url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	headers, err := json.Marshal(resp.Header())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode response headers: %s", err)
+	}
+
+	finalUrl := args.Url
+	if resp.RawResponse != nil && resp.RawResponse.Request != nil {
+		finalUrl = resp.RawResponse.Request.URL.String()
+	}
+
+	var contentType *string
+	if value := resp.Header().Get("Content-Type"); value != "" {
+		contentType = &value
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read page body: %s", err)
+	}
+
+	var skipReason *string
+	if len(body) > MaxPageSize {
+		reason := PageSkipTooLarge
+		skipReason = &reason
+		body = nil
+	}
+
+	var page model.Page
+	err = InTx(ctx, sa.PGClient, func(repo *Repository) error {
+		var bodyRef *string
+		if len(body) > 0 {
+			ref, err := repo.SavePageBody(ctx, body)
+			if err != nil {
+				return err
+			}
+
+			bodyRef = &ref
+		}
+
+		saved, err := repo.UpsertPage(ctx, model.Page{
+			EntityID:    args.EntityID,
+			UploadID:    args.UploadID,
+			UrlsetID:    args.UrlsetID,
+			URL:         args.Url,
+			FinalURL:    finalUrl,
+			StatusCode:  int32(resp.StatusCode()),
+			Headers:     string(headers),
+			ContentType: contentType,
+			BodyRef:     bodyRef,
+			SkipReason:  skipReason,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		page = saved
+
+		return repo.MarkUrlsetScraped(ctx, args.UrlsetID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if skipReason != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("Page %s is larger than %d bytes", args.Url, MaxPageSize), PageTooLargeErrorType, nil)
+	}
+
+	pageID := page.ID.String()
+
+	return &FetchPageRes{
+		PageID:     &pageID,
+		StatusCode: resp.StatusCode(),
+	}, nil
+}
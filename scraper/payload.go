@@ -0,0 +1,256 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const PayloadNotFoundErrorType = "PayloadNotFound"
+
+var (
+	ErrPayloadNotFound   = errors.New("payload not found")
+	ErrInvalidPayloadKey = errors.New("invalid payload key")
+)
+
+// PayloadStore hands parsed sitemaps from the activity that fetched them to the activities
+// that save them, since they are too large for Temporal payloads. Keys are derived from the
+// scope and the content: retried fetches of an unchanged sitemap reuse the same payload, while
+// workflows fetching the same bytes get their own copy and can delete it independently.
+type PayloadStore interface {
+	Put(ctx context.Context, scope string, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func PayloadKey(scope string, data []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(scope))
+	hash.Write([]byte{0})
+	hash.Write(data)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// payloadScope scopes payloads to the workflow run of the activity, which is the one that
+// deletes them.
+func payloadScope(ctx context.Context) string {
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return execution.ID + "/" + execution.RunID
+}
+
+// RedisPayloadStore keeps payloads in Redis. TTL is only a safety net for payloads whose
+// workflow never got to clean up, so it should comfortably exceed the activity retry window.
+type RedisPayloadStore struct {
+	Client *redis.Client
+	TTL    time.Duration
+}
+
+func redisPayloadKey(key string) string {
+	return fmt.Sprintf("mindex:payload:%s", key)
+}
+
+func (s *RedisPayloadStore) Put(ctx context.Context, scope string, data []byte) (string, error) {
+	key := PayloadKey(scope, data)
+
+	err := s.Client.Set(ctx, redisPayloadKey(key), data, s.TTL).Err()
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+
+	return key, nil
+}
+
+func (s *RedisPayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.Client.Get(ctx, redisPayloadKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("Failed to get payload %s: %w", key, ErrPayloadNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get payload %s: %s", key, err)
+	}
+
+	return data, nil
+}
+
+func (s *RedisPayloadStore) Delete(ctx context.Context, key string) error {
+	err := s.Client.Del(ctx, redisPayloadKey(key)).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to delete payload %s: %s", key, err)
+	}
+
+	return nil
+}
+
+// FilePayloadStore keeps payloads on a local disk, which only works when every worker
+// shares the directory.
+type FilePayloadStore struct {
+	Dir string
+}
+
+// path only accepts keys made by PayloadKey, so a key from elsewhere can neither be too short
+// for the directory prefix nor point outside Dir.
+func (s *FilePayloadStore) path(key string) (string, error) {
+	_, err := hex.DecodeString(key)
+	if err != nil || len(key) != 2*sha256.Size {
+		return "", fmt.Errorf("Failed to use payload %q: %w", key, ErrInvalidPayloadKey)
+	}
+
+	return filepath.Join(s.Dir, key[:2], key), nil
+}
+
+func (s *FilePayloadStore) Put(ctx context.Context, scope string, data []byte) (string, error) {
+	key := PayloadKey(scope, data)
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+
+	// Write to a temporary file first so readers never see a partial payload.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+
+	return key, nil
+}
+
+func (s *FilePayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Failed to get payload %s: %w", key, ErrPayloadNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get payload %s: %s", key, err)
+	}
+
+	return data, nil
+}
+
+func (s *FilePayloadStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Failed to delete payload %s: %s", key, err)
+	}
+
+	return nil
+}
+
+// S3PayloadStore keeps payloads in an S3-compatible bucket such as MinIO.
+type S3PayloadStore struct {
+	Client *minio.Client
+	Bucket string
+	Prefix string
+}
+
+func (s *S3PayloadStore) object(key string) string {
+	return s.Prefix + key
+}
+
+func (s *S3PayloadStore) Put(ctx context.Context, scope string, data []byte) (string, error) {
+	key := PayloadKey(scope, data)
+
+	_, err := s.Client.PutObject(ctx, s.Bucket, s.object(key), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to save payload %s: %s", key, err)
+	}
+
+	return key, nil
+}
+
+func (s *S3PayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	object, err := s.Client.GetObject(ctx, s.Bucket, s.object(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get payload %s: %s", key, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, fmt.Errorf("Failed to get payload %s: %w", key, ErrPayloadNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get payload %s: %s", key, err)
+	}
+
+	return data, nil
+}
+
+func (s *S3PayloadStore) Delete(ctx context.Context, key string) error {
+	err := s.Client.RemoveObject(ctx, s.Bucket, s.object(key), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to delete payload %s: %s", key, err)
+	}
+
+	return nil
+}
+
+// getPayload turns a missing payload or an invalid key into a non-retryable error: retrying
+// the save cannot bring it back, only fetching the sitemap again can.
+func (sa *ScraperActivities) getPayload(ctx context.Context, key string) ([]byte, error) {
+	data, err := sa.Payloads.Get(ctx, key)
+	if errors.Is(err, ErrPayloadNotFound) || errors.Is(err, ErrInvalidPayloadKey) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), PayloadNotFoundErrorType, err)
+	}
+
+	return data, err
+}
+
+type DeletePayloadArgs struct {
+	Key string `json:"key"`
+}
+
+// DeletePayload removes a payload once everything it carried has been saved.
+func (sa *ScraperActivities) DeletePayload(ctx context.Context, args DeletePayloadArgs) error {
+	return sa.Payloads.Delete(ctx, args.Key)
+}
@@ -0,0 +1,137 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/redis/go-redis/v9"
+)
+
+// testPayloadStore checks that workflows putting the same bytes do not share a payload,
+// so one of them cleaning up does not break the other.
+func testPayloadStore(t *testing.T, store PayloadStore) {
+	ctx := context.Background()
+	data := []byte(`{"urlset":[{"loc":"https://example.com/"}]}`)
+
+	first, err := store.Put(ctx, "crawl-a/run-1", data)
+	if err != nil {
+		t.Fatalf("Put() error = %s", err)
+	}
+
+	retried, err := store.Put(ctx, "crawl-a/run-1", data)
+	if err != nil {
+		t.Fatalf("Put() error = %s", err)
+	}
+
+	if retried != first {
+		t.Errorf("Put() in the same scope = %s, want %s", retried, first)
+	}
+
+	second, err := store.Put(ctx, "crawl-b/run-1", data)
+	if err != nil {
+		t.Fatalf("Put() error = %s", err)
+	}
+
+	if second == first {
+		t.Fatalf("Put() in another scope reused key %s", first)
+	}
+
+	err = store.Delete(ctx, first)
+	if err != nil {
+		t.Fatalf("Delete() error = %s", err)
+	}
+
+	_, err = store.Get(ctx, first)
+	if !errors.Is(err, ErrPayloadNotFound) {
+		t.Errorf("Get() of deleted payload error = %v, want ErrPayloadNotFound", err)
+	}
+
+	got, err := store.Get(ctx, second)
+	if err != nil {
+		t.Fatalf("Get() of the other scope error = %s", err)
+	}
+
+	if string(got) != string(data) {
+		t.Errorf("Get() = %s, want %s", got, data)
+	}
+
+	err = store.Delete(ctx, second)
+	if err != nil {
+		t.Fatalf("Delete() error = %s", err)
+	}
+}
+
+func TestFilePayloadStore(t *testing.T) {
+	testPayloadStore(t, &FilePayloadStore{Dir: t.TempDir()})
+}
+
+func TestFilePayloadStoreInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store := &FilePayloadStore{Dir: t.TempDir()}
+
+	for _, key := range []string{
+		"",
+		"a",
+		"ab",
+		"../../etc/passwd",
+		PayloadKey("scope", nil)[:63] + "/",
+		PayloadKey("scope", nil) + "0",
+	} {
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidPayloadKey) {
+			t.Errorf("Get(%q) error = %v, want %s", key, err, ErrInvalidPayloadKey)
+		}
+
+		if err := store.Delete(ctx, key); !errors.Is(err, ErrInvalidPayloadKey) {
+			t.Errorf("Delete(%q) error = %v, want %s", key, err, ErrInvalidPayloadKey)
+		}
+	}
+}
+
+func TestRedisPayloadStore(t *testing.T) {
+	addr := os.Getenv("MINDEX_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("MINDEX_TEST_REDIS_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	testPayloadStore(t, &RedisPayloadStore{Client: client, TTL: time.Minute})
+}
+
+// TestS3PayloadStore runs against MinIO or any other S3-compatible endpoint.
+func TestS3PayloadStore(t *testing.T) {
+	endpoint := os.Getenv("MINDEX_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINDEX_TEST_S3_ENDPOINT is not set")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(os.Getenv("MINDEX_TEST_S3_ACCESS_KEY"), os.Getenv("MINDEX_TEST_S3_SECRET_KEY"), ""),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create S3 client: %s", err)
+	}
+
+	ctx := context.Background()
+	bucket := "mindex-test"
+
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		t.Fatalf("Failed to check bucket: %s", err)
+	}
+
+	if !exists {
+		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			t.Fatalf("Failed to create bucket: %s", err)
+		}
+	}
+
+	testPayloadStore(t, &S3PayloadStore{Client: client, Bucket: bucket, Prefix: "payloads/"})
+}
@@ -0,0 +1,205 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// reserveScript implements a token bucket that refills one token per interval.
+// The bucket may go negative so concurrent workers queue up behind each other, but only up
+// to ARGV[3] milliseconds: a caller that would have to wait longer takes no token and leaves
+// the bucket untouched. The script returns {reserved, wait}.
+// Redis TIME is used instead of worker clocks, which may drift apart.
+var reserveScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local interval = tonumber(ARGV[1])
+local delay = redis.call("GET", KEYS[2])
+if delay then
+	interval = tonumber(delay)
+end
+
+local burst = tonumber(ARGV[2])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+if interval > 0 then
+	tokens = math.min(burst, tokens + (now - ts) / interval)
+else
+	tokens = burst
+end
+
+tokens = tokens - 1
+
+local wait = 0
+if tokens < 0 then
+	wait = math.ceil(-tokens * interval)
+end
+
+if wait > tonumber(ARGV[3]) then
+	return {0, wait}
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], wait + interval * burst + 1000)
+
+return {1, wait}
+`)
+
+// HostLimiter throttles requests per host across every worker sharing the same Redis.
+// Crawl-delay from robots.txt overrides DefaultDelay for the host it was read from.
+type HostLimiter struct {
+	Client       *redis.Client
+	DefaultDelay time.Duration
+	Burst        int
+}
+
+func rateLimitKey(host string) string {
+	return fmt.Sprintf("mindex:ratelimit:%s", host)
+}
+
+func crawlDelayKey(host string) string {
+	return fmt.Sprintf("mindex:crawl-delay:%s", host)
+}
+
+func limiterHost(rawUrl string) (string, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse url %s: %s", rawUrl, err)
+	}
+
+	return strings.ToLower(parsed.Host), nil
+}
+
+// ErrHostBusy is returned by Reserve when the host is booked for longer than the caller may wait.
+var ErrHostBusy = errors.New("host is busy")
+
+// Reserve books the next request slot for the host of rawUrl and returns how long the caller has
+// to wait before sending it. When the wait would exceed maxWait nothing is booked, and the wait
+// is returned together with ErrHostBusy so the caller can come back later.
+func (l *HostLimiter) Reserve(ctx context.Context, rawUrl string, maxWait time.Duration) (time.Duration, error) {
+	host, err := limiterHost(rawUrl)
+	if err != nil {
+		return 0, err
+	}
+
+	burst := max(l.Burst, 1)
+	res, err := reserveScript.Run(ctx, l.Client,
+		[]string{rateLimitKey(host), crawlDelayKey(host)},
+		l.DefaultDelay.Milliseconds(), burst, maxWait.Milliseconds(),
+	).Int64Slice()
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to reserve rate limit for %s: %s", host, err)
+	}
+
+	if len(res) != 2 {
+		return 0, fmt.Errorf("Failed to reserve rate limit for %s: unexpected reply %v", host, res)
+	}
+
+	wait := time.Duration(res[1]) * time.Millisecond
+	if res[0] == 0 {
+		return wait, ErrHostBusy
+	}
+
+	return wait, nil
+}
+
+// SetCrawlDelay records the Crawl-delay of a host, capped at MaxCrawlDelay.
+// A missing Crawl-delay clears the override so DefaultDelay applies again.
+func (l *HostLimiter) SetCrawlDelay(ctx context.Context, rawUrl string, delay time.Duration, ok bool) error {
+	host, err := limiterHost(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return l.Client.Del(ctx, crawlDelayKey(host)).Err()
+	}
+
+	delay = min(delay, MaxCrawlDelay)
+
+	return l.Client.Set(ctx, crawlDelayKey(host), delay.Milliseconds(), CrawlDelayTTL).Err()
+}
+
+// waitForHost waits for the turn of the activity at the host of rawUrl, heartbeating meanwhile.
+// It waits at most MaxHostWait and never more than half of the time left to the activity, so
+// the request itself still fits. A longer queue fails with a RateLimitedErrorType error whose
+// details and NextRetryDelay carry the wait, without booking a slot.
+func (sa *ScraperActivities) waitForHost(ctx context.Context, rawUrl string) error {
+	if sa.Limiter == nil {
+		return nil
+	}
+
+	maxWait := MaxHostWait
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = max(min(maxWait, time.Until(deadline)/2), 0)
+	}
+
+	wait, err := sa.Limiter.Reserve(ctx, rawUrl, maxWait)
+	if errors.Is(err, ErrHostBusy) {
+		return temporal.NewApplicationErrorWithOptions(
+			fmt.Sprintf("Host of %s is busy for %s", rawUrl, wait),
+			RateLimitedErrorType,
+			temporal.ApplicationErrorOptions{
+				NextRetryDelay: wait,
+				Details:        []any{wait},
+			},
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	ticker := time.NewTicker(HostWaitHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			activity.RecordHeartbeat(ctx)
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// hostRetryAfter reports how long to wait before retrying an activity that failed with
+// RateLimitedErrorType.
+func hostRetryAfter(err error) (time.Duration, bool) {
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != RateLimitedErrorType {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if appErr.Details(&wait) != nil {
+		return 0, false
+	}
+
+	return max(wait, time.Second), true
+}
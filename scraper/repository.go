@@ -0,0 +1,338 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+)
+
+// sitemapInsertBatchSize keeps a single INSERT well below the Postgres limit of 65535 parameters.
+const sitemapInsertBatchSize = 5000
+
+// Repository wraps writes to the crawl tables. It runs against either *sql.DB or *sql.Tx,
+// so the caller decides what is committed atomically; use InTx for anything spanning
+// several statements.
+type Repository struct {
+	db qrm.DB
+}
+
+func NewRepository(db qrm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// InTx runs fn with a repository bound to one transaction. The transaction is committed only
+// if fn succeeds, otherwise nothing fn wrote is kept.
+func InTx(ctx context.Context, db *sql.DB, fn func(repo *Repository) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to start DB transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	err = fn(NewRepository(tx))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Failed to commit transaction: %s", err)
+	}
+
+	return nil
+}
+
+// UpsertSitemapIndex inserts sitemap index rows, refreshing lastmod of rows already saved for
+// the same entity, upload and canonical URL.
+func (r *Repository) UpsertSitemapIndex(ctx context.Context, rows []model.SitemapIndex) error {
+	// Postgres refuses to upsert the same row twice in one statement.
+	rows = uniqueBy(rows, func(row model.SitemapIndex) string { return row.CanonicalURL })
+
+	for batch := range slices.Chunk(rows, sitemapInsertBatchSize) {
+		_, err := SitemapIndex.INSERT(
+			SitemapIndex.EntityID,
+			SitemapIndex.UploadID,
+			SitemapIndex.RobotsID,
+			SitemapIndex.OriginID,
+			SitemapIndex.URL,
+			SitemapIndex.CanonicalURL,
+			SitemapIndex.LastModified,
+			SitemapIndex.Scraped,
+		).
+			MODELS(batch).
+			ON_CONFLICT(SitemapIndex.EntityID, SitemapIndex.UploadID, SitemapIndex.CanonicalURL).
+			DO_UPDATE(SET(
+				SitemapIndex.LastModified.SET(SitemapIndex.EXCLUDED.LastModified),
+				SitemapIndex.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+			)).
+			ExecContext(ctx, r.db)
+
+		if err != nil {
+			return fmt.Errorf("Failed to save sitemap index: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// UpsertSitemapUrlset inserts urlset rows, refreshing lastmod, changefreq and priority of rows
+// already saved for the same entity, upload and canonical URL.
+func (r *Repository) UpsertSitemapUrlset(ctx context.Context, rows []model.SitemapUrlset) error {
+	rows = uniqueBy(rows, func(row model.SitemapUrlset) string { return row.CanonicalURL })
+
+	for batch := range slices.Chunk(rows, sitemapInsertBatchSize) {
+		_, err := SitemapUrlset.INSERT(
+			SitemapUrlset.EntityID,
+			SitemapUrlset.UploadID,
+			SitemapUrlset.RobotsID,
+			SitemapUrlset.OriginID,
+			SitemapUrlset.URL,
+			SitemapUrlset.CanonicalURL,
+			SitemapUrlset.LastModified,
+			SitemapUrlset.ChangeFreq,
+			SitemapUrlset.Priority,
+			SitemapUrlset.Scraped,
+		).
+			MODELS(batch).
+			ON_CONFLICT(SitemapUrlset.EntityID, SitemapUrlset.UploadID, SitemapUrlset.CanonicalURL).
+			DO_UPDATE(SET(
+				SitemapUrlset.LastModified.SET(SitemapUrlset.EXCLUDED.LastModified),
+				SitemapUrlset.ChangeFreq.SET(SitemapUrlset.EXCLUDED.ChangeFreq),
+				SitemapUrlset.Priority.SET(SitemapUrlset.EXCLUDED.Priority),
+				SitemapUrlset.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+			)).
+			ExecContext(ctx, r.db)
+
+		if err != nil {
+			return fmt.Errorf("Failed to save urlset: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// SavePageBody stores a page body under the hex SHA-256 of its content and returns that
+// reference. Bodies shared by several pages are stored once.
+func (r *Repository) SavePageBody(ctx context.Context, body []byte) (string, error) {
+	hash := sha256.Sum256(body)
+	ref := hex.EncodeToString(hash[:])
+
+	_, err := PageBody.INSERT(PageBody.Hash, PageBody.Data).
+		MODEL(model.PageBody{
+			Hash: ref,
+			Data: body,
+		}).
+		ON_CONFLICT(PageBody.Hash).
+		DO_NOTHING().
+		ExecContext(ctx, r.db)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to save page body: %s", err)
+	}
+
+	return ref, nil
+}
+
+// UpsertPage saves the page fetched for an urlset row. Fetching the row again, e.g. when the
+// activity is retried, replaces the previous page.
+func (r *Repository) UpsertPage(ctx context.Context, page model.Page) (model.Page, error) {
+	var saved model.Page
+	err := Page.INSERT(
+		Page.EntityID,
+		Page.UploadID,
+		Page.UrlsetID,
+		Page.URL,
+		Page.FinalURL,
+		Page.StatusCode,
+		Page.Headers,
+		Page.ContentType,
+		Page.BodyRef,
+		Page.SkipReason,
+	).
+		MODEL(page).
+		ON_CONFLICT(Page.UrlsetID).
+		DO_UPDATE(SET(
+			Page.URL.SET(Page.EXCLUDED.URL),
+			Page.FinalURL.SET(Page.EXCLUDED.FinalURL),
+			Page.StatusCode.SET(Page.EXCLUDED.StatusCode),
+			Page.Headers.SET(Page.EXCLUDED.Headers),
+			Page.ContentType.SET(Page.EXCLUDED.ContentType),
+			Page.BodyRef.SET(Page.EXCLUDED.BodyRef),
+			Page.SkipReason.SET(Page.EXCLUDED.SkipReason),
+			Page.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+		)).
+		RETURNING(Page.AllColumns).
+		QueryContext(ctx, r.db, &saved)
+
+	if err != nil {
+		return saved, fmt.Errorf("Failed to save page: %s", err)
+	}
+
+	return saved, nil
+}
+
+func (r *Repository) MarkUrlsetScraped(ctx context.Context, id uuid.UUID) error {
+	_, err := SitemapUrlset.UPDATE(SitemapUrlset.Scraped, SitemapUrlset.UpdatedAt).
+		SET(Bool(true), CURRENT_TIMESTAMP()).
+		WHERE(SitemapUrlset.ID.EQ(UUID(id))).
+		ExecContext(ctx, r.db)
+
+	if err != nil {
+		return fmt.Errorf("Failed to mark urlset as scraped: %s", err)
+	}
+
+	return nil
+}
+
+// UpsertDocument saves the document extracted from a page. Extracting the same page again
+// replaces the previous document.
+func (r *Repository) UpsertDocument(ctx context.Context, document model.Document) (model.Document, error) {
+	var saved model.Document
+	err := Document.INSERT(
+		Document.EntityID,
+		Document.UploadID,
+		Document.PageID,
+		Document.URL,
+		Document.Title,
+		Document.Description,
+		Document.Headings,
+		Document.Language,
+		Document.CanonicalURL,
+		Document.Content,
+		Document.Links,
+	).
+		MODEL(document).
+		ON_CONFLICT(Document.PageID).
+		DO_UPDATE(SET(
+			Document.URL.SET(Document.EXCLUDED.URL),
+			Document.Title.SET(Document.EXCLUDED.Title),
+			Document.Description.SET(Document.EXCLUDED.Description),
+			Document.Headings.SET(Document.EXCLUDED.Headings),
+			Document.Language.SET(Document.EXCLUDED.Language),
+			Document.CanonicalURL.SET(Document.EXCLUDED.CanonicalURL),
+			Document.Content.SET(Document.EXCLUDED.Content),
+			Document.Links.SET(Document.EXCLUDED.Links),
+			Document.UpdatedAt.SET(CURRENT_TIMESTAMP()),
+		)).
+		RETURNING(Document.AllColumns).
+		QueryContext(ctx, r.db, &saved)
+
+	if err != nil {
+		return saved, fmt.Errorf("Failed to save document: %s", err)
+	}
+
+	return saved, nil
+}
+
+// ReplacePageLinks replaces the outgoing links stored for a page.
+func (r *Repository) ReplacePageLinks(ctx context.Context, pageID uuid.UUID, rows []model.Link) error {
+	_, err := Link.DELETE().
+		WHERE(Link.PageID.EQ(UUID(pageID))).
+		ExecContext(ctx, r.db)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete page links: %s", err)
+	}
+
+	rows = uniqueBy(rows, func(row model.Link) string { return row.CanonicalURL })
+
+	for batch := range slices.Chunk(rows, sitemapInsertBatchSize) {
+		_, err := Link.INSERT(
+			Link.EntityID,
+			Link.UploadID,
+			Link.PageID,
+			Link.URL,
+			Link.CanonicalURL,
+			Link.AnchorText,
+			Link.Rel,
+			Link.Nofollow,
+		).
+			MODELS(batch).
+			ExecContext(ctx, r.db)
+
+		if err != nil {
+			return fmt.Errorf("Failed to save page links: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// EnqueueUrlset adds urlset rows for URLs not yet known in the upload and returns how many were
+// new. Rows already present, from a sitemap or an earlier link, are left untouched.
+func (r *Repository) EnqueueUrlset(ctx context.Context, rows []model.SitemapUrlset) (int, error) {
+	rows = uniqueBy(rows, func(row model.SitemapUrlset) string { return row.CanonicalURL })
+
+	enqueued := 0
+	for batch := range slices.Chunk(rows, sitemapInsertBatchSize) {
+		res, err := SitemapUrlset.INSERT(
+			SitemapUrlset.EntityID,
+			SitemapUrlset.UploadID,
+			SitemapUrlset.RobotsID,
+			SitemapUrlset.URL,
+			SitemapUrlset.CanonicalURL,
+			SitemapUrlset.Source,
+			SitemapUrlset.Depth,
+			SitemapUrlset.Scraped,
+		).
+			MODELS(batch).
+			ON_CONFLICT(SitemapUrlset.EntityID, SitemapUrlset.UploadID, SitemapUrlset.CanonicalURL).
+			DO_NOTHING().
+			ExecContext(ctx, r.db)
+
+		if err != nil {
+			return enqueued, fmt.Errorf("Failed to enqueue urlset: %s", err)
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return enqueued, fmt.Errorf("Failed to count enqueued urlset: %s", err)
+		}
+
+		enqueued += int(inserted)
+	}
+
+	return enqueued, nil
+}
+
+// MarkDocumentIndexed records which version of a document, identified by its updated_at, is in
+// the search index.
+func (r *Repository) MarkDocumentIndexed(ctx context.Context, id uuid.UUID, version time.Time) error {
+	_, err := Document.UPDATE(Document.IndexedAt).
+		SET(TimestampzT(version)).
+		WHERE(Document.ID.EQ(UUID(id))).
+		ExecContext(ctx, r.db)
+
+	if err != nil {
+		return fmt.Errorf("Failed to mark document as indexed: %s", err)
+	}
+
+	return nil
+}
+
+func uniqueBy[T any](rows []T, key func(T) string) []T {
+	seen := make(map[string]bool, len(rows))
+	unique := rows[:0:0]
+
+	for _, row := range rows {
+		if seen[key(row)] {
+			continue
+		}
+		seen[key(row)] = true
+
+		unique = append(unique, row)
+	}
+
+	return unique
+}
@@ -0,0 +1,268 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.temporal.io/sdk/testsuite"
+	"resty.dev/v3"
+
+	"github.com/immz4/mindex/scraper/migrations"
+)
+
+// testDB connects to the database in MINDEX_TEST_POSTGRES_DSN and migrates it. The database
+// needs the timescaledb extension, like the devenv one.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("MINDEX_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("MINDEX_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open PG connection: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = (&migrations.Migrator{DB: db}).Up(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to migrate: %s", err)
+	}
+
+	return db
+}
+
+type testCrawl struct {
+	EntityID uuid.UUID
+	UploadID uuid.UUID
+	RobotsID uuid.UUID
+}
+
+// newTestCrawl adds an entity with an allow-all robots.txt, removed again when the test ends.
+func newTestCrawl(t *testing.T, db *sql.DB) testCrawl {
+	t.Helper()
+	ctx := context.Background()
+
+	host := fmt.Sprintf("%s.example.com", uuid.NewString())
+	added, err := AddEntities(ctx, db, []EntityInput{{Name: host, Url: host}})
+	if err != nil || len(added) != 1 {
+		t.Fatalf("Failed to add entity: %v", err)
+	}
+
+	crawl := testCrawl{EntityID: added[0].ID, UploadID: uuid.New()}
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), "DELETE FROM entity WHERE id = $1", crawl.EntityID)
+	})
+
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO robots (entity_id, upload_id, data, status_code, policy)
+		VALUES ($1, $2, '', 404, $3)
+		RETURNING id`,
+		crawl.EntityID, crawl.UploadID, RobotsPolicyAllowAll,
+	).Scan(&crawl.RobotsID)
+	if err != nil {
+		t.Fatalf("Failed to add robots.txt: %s", err)
+	}
+
+	return crawl
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+
+	var count int
+	err := db.QueryRowContext(context.Background(), query, args...).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %s", err)
+	}
+
+	return count
+}
+
+func putTestPayload(t *testing.T, store PayloadStore, value any) string {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to encode payload: %s", err)
+	}
+
+	key, err := store.Put(context.Background(), t.Name(), data)
+	if err != nil {
+		t.Fatalf("Failed to save payload: %s", err)
+	}
+
+	return key
+}
+
+func newTestActivityEnvironment(sa *ScraperActivities) *testsuite.TestActivityEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(sa)
+
+	return env
+}
+
+// A payload that went missing after the first chunk was saved fails the activity, and the
+// chunk saved before it must be rolled back with it.
+func TestSaveSitemapUrlsetRollsBack(t *testing.T) {
+	db := testDB(t)
+	crawl := newTestCrawl(t, db)
+
+	sa := &ScraperActivities{PGClient: db, Payloads: &FilePayloadStore{Dir: t.TempDir()}}
+	env := newTestActivityEnvironment(sa)
+
+	saved := putTestPayload(t, sa.Payloads, SitemapUrlsetParsed{Urlset: []SitemapResUrlset{
+		{Location: "https://example.com/a"},
+		{Location: "https://example.com/b"},
+	}})
+
+	_, err := env.ExecuteActivity(sa.SaveSitemapUrlset, SaveSitemapArgs{
+		UploadID: crawl.UploadID,
+		EntityID: crawl.EntityID,
+		RobotsID: crawl.RobotsID,
+		SaveIDs:  []string{saved, PayloadKey(t.Name(), []byte("missing"))},
+	})
+	if err == nil {
+		t.Fatal("SaveSitemapUrlset() succeeded with a missing payload")
+	}
+
+	count := countRows(t, db, "SELECT count(*) FROM sitemap_urlset WHERE entity_id = $1", crawl.EntityID)
+	if count != 0 {
+		t.Errorf("sitemap_urlset has %d rows after rollback, want 0", count)
+	}
+}
+
+func TestSaveSitemapIndexRollsBack(t *testing.T) {
+	db := testDB(t)
+	crawl := newTestCrawl(t, db)
+
+	sa := &ScraperActivities{PGClient: db, Payloads: &FilePayloadStore{Dir: t.TempDir()}}
+	env := newTestActivityEnvironment(sa)
+
+	saved := putTestPayload(t, sa.Payloads, SitemapIndexParsed{Index: []SitemapResIndex{
+		{Location: "https://example.com/sitemap-1.xml"},
+		{Location: "https://example.com/sitemap-2.xml"},
+	}})
+
+	_, err := env.ExecuteActivity(sa.SaveSitemapIndex, SaveSitemapArgs{
+		UploadID: crawl.UploadID,
+		EntityID: crawl.EntityID,
+		RobotsID: crawl.RobotsID,
+		SaveIDs:  []string{saved, PayloadKey(t.Name(), []byte("missing"))},
+	})
+	if err == nil {
+		t.Fatal("SaveSitemapIndex() succeeded with a missing payload")
+	}
+
+	count := countRows(t, db, "SELECT count(*) FROM sitemap_index WHERE entity_id = $1", crawl.EntityID)
+	if count != 0 {
+		t.Errorf("sitemap_index has %d rows after rollback, want 0", count)
+	}
+}
+
+func TestFetchPageStoresPageAndBodyTogether(t *testing.T) {
+	db := testDB(t)
+	crawl := newTestCrawl(t, db)
+
+	body := fmt.Sprintf("<html><title>%s</title></html>", t.Name())
+	hash := sha256.Sum256([]byte(body))
+	bodyRef := hex.EncodeToString(hash[:])
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), "DELETE FROM page_body WHERE hash = $1", bodyRef)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client := resty.New()
+	t.Cleanup(func() { client.Close() })
+
+	sa := &ScraperActivities{PGClient: db, HTTPClient: client}
+	env := newTestActivityEnvironment(sa)
+
+	t.Run("failed insert keeps no body", func(t *testing.T) {
+		// The urlset row does not exist, so the page insert fails after the body was saved.
+		_, err := env.ExecuteActivity(sa.FetchPage, FetchPageArgs{
+			EntityID: crawl.EntityID,
+			UploadID: crawl.UploadID,
+			UrlsetID: uuid.New(),
+			Url:      server.URL + "/",
+		})
+		if err == nil {
+			t.Fatal("FetchPage() succeeded without an urlset row")
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM page_body WHERE hash = $1", bodyRef); count != 0 {
+			t.Errorf("page_body has %d rows after rollback, want 0", count)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM page WHERE entity_id = $1", crawl.EntityID); count != 0 {
+			t.Errorf("page has %d rows after rollback, want 0", count)
+		}
+	})
+
+	t.Run("success stores both", func(t *testing.T) {
+		var urlsetID uuid.UUID
+		err := db.QueryRowContext(context.Background(), `
+			INSERT INTO sitemap_urlset (entity_id, upload_id, robots_id, url, canonical_url)
+			VALUES ($1, $2, $3, $4, $4)
+			RETURNING id`,
+			crawl.EntityID, crawl.UploadID, crawl.RobotsID, server.URL+"/",
+		).Scan(&urlsetID)
+		if err != nil {
+			t.Fatalf("Failed to add urlset row: %s", err)
+		}
+
+		_, err = env.ExecuteActivity(sa.FetchPage, FetchPageArgs{
+			EntityID: crawl.EntityID,
+			UploadID: crawl.UploadID,
+			UrlsetID: urlsetID,
+			Url:      server.URL + "/",
+		})
+		if err != nil {
+			t.Fatalf("FetchPage() error = %s", err)
+		}
+
+		count := countRows(t, db, `
+			SELECT count(*) FROM page
+			JOIN page_body ON page_body.hash = page.body_ref
+			JOIN sitemap_urlset ON sitemap_urlset.id = page.urlset_id AND sitemap_urlset.scraped
+			WHERE page.entity_id = $1`,
+			crawl.EntityID,
+		)
+		if count != 1 {
+			t.Errorf("found %d scraped pages with a body, want 1", count)
+		}
+
+		// A retried fetch of the same urlset row replaces the page instead of adding another.
+		_, err = env.ExecuteActivity(sa.FetchPage, FetchPageArgs{
+			EntityID: crawl.EntityID,
+			UploadID: crawl.UploadID,
+			UrlsetID: urlsetID,
+			Url:      server.URL + "/",
+		})
+		if err != nil {
+			t.Fatalf("FetchPage() retry error = %s", err)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM page WHERE urlset_id = $1", urlsetID); count != 1 {
+			t.Errorf("page has %d rows for the urlset after a retry, want 1", count)
+		}
+	})
+}
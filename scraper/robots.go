@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jimsmart/grobotstxt"
+	"resty.dev/v3"
+)
+
+const (
+	RobotsPolicyRules       = "rules"
+	RobotsPolicyAllowAll    = "allow_all"
+	RobotsPolicyDisallowAll = "disallow_all"
+
+	RobotsUnavailableErrorType = "RobotsUnavailable"
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// RedirectPolicy stops after maxRedirects hops with an error wrapping ErrTooManyRedirects,
+// so activities can tell redirect loops apart from network failures.
+func RedirectPolicy(maxRedirects int) resty.RedirectPolicy {
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects: %w", maxRedirects, ErrTooManyRedirects)
+		}
+
+		return nil
+	})
+}
+
+// crawlDelayExtractor collects Crawl-delay values for a user agent, following the same
+// group semantics as grobotstxt: a group addressing the agent directly wins over "*".
+type crawlDelayExtractor struct {
+	userAgent string
+
+	inGlobalGroup   bool
+	inSpecificGroup bool
+	seenSeparator   bool
+
+	globalDelay   *time.Duration
+	specificDelay *time.Duration
+}
+
+func robotsCrawlDelay(robotsBody string, userAgent string) (time.Duration, bool) {
+	extractor := &crawlDelayExtractor{userAgent: userAgent}
+	grobotstxt.Parse(robotsBody, extractor)
+
+	if extractor.specificDelay != nil {
+		return *extractor.specificDelay, true
+	}
+
+	if extractor.globalDelay != nil {
+		return *extractor.globalDelay, true
+	}
+
+	return 0, false
+}
+
+func (e *crawlDelayExtractor) HandleRobotsStart() {}
+
+func (e *crawlDelayExtractor) HandleRobotsEnd() {}
+
+func (e *crawlDelayExtractor) HandleUserAgent(lineNum int, value string) {
+	if e.seenSeparator {
+		e.inGlobalGroup = false
+		e.inSpecificGroup = false
+		e.seenSeparator = false
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "*" || strings.HasPrefix(value, "* ") {
+		e.inGlobalGroup = true
+		return
+	}
+
+	if product, _, _ := strings.Cut(value, "/"); strings.EqualFold(product, e.userAgent) {
+		e.inSpecificGroup = true
+	}
+}
+
+func (e *crawlDelayExtractor) HandleAllow(lineNum int, value string) {
+	e.seenSeparator = true
+}
+
+func (e *crawlDelayExtractor) HandleDisallow(lineNum int, value string) {
+	e.seenSeparator = true
+}
+
+func (e *crawlDelayExtractor) HandleSitemap(lineNum int, value string) {}
+
+func (e *crawlDelayExtractor) HandleUnknownAction(lineNum int, action, value string) {
+	e.seenSeparator = true
+
+	if !strings.EqualFold(action, "crawl-delay") {
+		return
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds < 0 {
+		return
+	}
+
+	delay := time.Duration(seconds * float64(time.Second))
+
+	if e.inSpecificGroup && e.specificDelay == nil {
+		e.specificDelay = &delay
+	}
+
+	if e.inGlobalGroup && e.globalDelay == nil {
+		e.globalDelay = &delay
+	}
+}
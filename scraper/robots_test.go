@@ -0,0 +1,199 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+	"resty.dev/v3"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+)
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"no delay", "User-agent: *\nDisallow: /private\n", 0, false},
+		{"global delay", "User-agent: *\nCrawl-delay: 2\n", 2 * time.Second, true},
+		{"fractional delay", "User-agent: *\nCrawl-delay: 0.5\n", 500 * time.Millisecond, true},
+		{"specific agent wins over star", "User-agent: *\nCrawl-delay: 10\n\nUser-agent: MindexBot\nCrawl-delay: 1\n", time.Second, true},
+		{"specific agent wins when listed first", "User-agent: MindexBot\nCrawl-delay: 1\n\nUser-agent: *\nCrawl-delay: 10\n", time.Second, true},
+		{"agent matched without version", "User-agent: MindexBot/2.0\nCrawl-delay: 3\n", 3 * time.Second, true},
+		{"agent matched in any case", "User-agent: mindexbot\nCrawl-delay: 3\n", 3 * time.Second, true},
+		{"other agents ignored", "User-agent: OtherBot\nCrawl-delay: 5\n", 0, false},
+		{"star applies when agent has no delay", "User-agent: MindexBot\nDisallow: /a\n\nUser-agent: *\nCrawl-delay: 4\n", 4 * time.Second, true},
+		{"shared group", "User-agent: OtherBot\nUser-agent: MindexBot\nCrawl-delay: 6\n", 6 * time.Second, true},
+		{"group ends at next user agent after rules", "User-agent: MindexBot\nDisallow: /a\nUser-agent: OtherBot\nCrawl-delay: 5\n", 0, false},
+		{"first delay of a group wins", "User-agent: *\nCrawl-delay: 2\nCrawl-delay: 8\n", 2 * time.Second, true},
+		{"invalid delay ignored", "User-agent: *\nCrawl-delay: soon\n", 0, false},
+		{"negative delay ignored", "User-agent: *\nCrawl-delay: -1\n", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := robotsCrawlDelay(test.robots, "MindexBot")
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("robotsCrawlDelay() = %s, %t, want %s, %t", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
+
+func newTestRobotsActivities(t *testing.T) *ScraperActivities {
+	t.Helper()
+
+	client := resty.New().SetRedirectPolicy(RedirectPolicy(MaxRedirects))
+	t.Cleanup(func() { client.Close() })
+
+	return &ScraperActivities{HTTPClient: client, UserAgent: "MindexBot"}
+}
+
+func robotsErrorType(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Type()
+	}
+
+	return ""
+}
+
+func TestFetchRobots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rules/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\nSitemap: https://example.com/sitemap.xml\nSitemap: https://example.com/sitemap.xml\n")
+	})
+	mux.HandleFunc("/missing/robots.txt", http.NotFound)
+	mux.HandleFunc("/forbidden/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/error/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/busy/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/loop/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop/robots.txt", http.StatusFound)
+	})
+	// /hops/N/robots.txt redirects N more times before serving rules.
+	mux.HandleFunc("/hops/{n}/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscan(r.PathValue("n"), &n)
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hops/%d/robots.txt", n-1), http.StatusFound)
+			return
+		}
+
+		fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	sa := newTestRobotsActivities(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantPolicy string
+		wantStatus int
+	}{
+		{"rules", "/rules/robots.txt", RobotsPolicyRules, http.StatusOK},
+		{"not found allows all", "/missing/robots.txt", RobotsPolicyAllowAll, http.StatusNotFound},
+		{"forbidden allows all", "/forbidden/robots.txt", RobotsPolicyAllowAll, http.StatusForbidden},
+		{"redirects within limit", fmt.Sprintf("/hops/%d/robots.txt", MaxRobotsRedirects), RobotsPolicyRules, http.StatusOK},
+		{"more redirects than robots.txt allows", fmt.Sprintf("/hops/%d/robots.txt", MaxRobotsRedirects+1), RobotsPolicyAllowAll, http.StatusOK},
+		{"redirect loop allows all", "/loop/robots.txt", RobotsPolicyAllowAll, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			robots, err := sa.fetchRobots(context.Background(), server.URL+test.path, nil)
+			if err != nil {
+				t.Fatalf("fetchRobots() error = %s", err)
+			}
+
+			if robots.Policy != test.wantPolicy || robots.StatusCode != test.wantStatus {
+				t.Errorf("fetchRobots() = policy %s, status %d, want policy %s, status %d",
+					robots.Policy, robots.StatusCode, test.wantPolicy, test.wantStatus)
+			}
+		})
+	}
+
+	t.Run("rules are parsed", func(t *testing.T) {
+		robots, err := sa.fetchRobots(context.Background(), server.URL+"/rules/robots.txt", nil)
+		if err != nil {
+			t.Fatalf("fetchRobots() error = %s", err)
+		}
+
+		if want := []string{"https://example.com/sitemap.xml"}; !slices.Equal(robots.Sitemap, want) {
+			t.Errorf("fetchRobots() sitemaps = %v, want %v", robots.Sitemap, want)
+		}
+
+		if robots.ETag == nil || *robots.ETag != `"v1"` {
+			t.Errorf("fetchRobots() etag = %v, want %q", robots.ETag, `"v1"`)
+		}
+	})
+
+	for _, path := range []string{"/error/robots.txt", "/busy/robots.txt"} {
+		t.Run("unavailable "+path, func(t *testing.T) {
+			robots, err := sa.fetchRobots(context.Background(), server.URL+path, nil)
+			if got := robotsErrorType(err); got != RobotsUnavailableErrorType {
+				t.Errorf("fetchRobots() = %v, %v, want a %s error", robots, err, RobotsUnavailableErrorType)
+			}
+		})
+	}
+}
+
+func TestFetchRobotsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL + "/robots.txt"
+	server.Close()
+
+	robots, err := newTestRobotsActivities(t).fetchRobots(context.Background(), url, nil)
+	if got := robotsErrorType(err); got != RobotsUnavailableErrorType {
+		t.Errorf("fetchRobots() = %v, %v, want a %s error", robots, err, RobotsUnavailableErrorType)
+	}
+}
+
+func TestFetchRobotsNotModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+	}))
+	t.Cleanup(server.Close)
+
+	etag := `"v1"`
+	previous := &model.Robots{
+		ID:         uuid.New(),
+		Data:       "User-agent: *\nDisallow: /private\n",
+		StatusCode: http.StatusOK,
+		Policy:     RobotsPolicyRules,
+		Etag:       &etag,
+	}
+
+	robots, err := newTestRobotsActivities(t).fetchRobots(context.Background(), server.URL+"/robots.txt", previous)
+	if err != nil {
+		t.Fatalf("fetchRobots() error = %s", err)
+	}
+
+	if !robots.NotModified || robots.Text != previous.Data || robots.ID == nil || *robots.ID != previous.ID.String() {
+		t.Errorf("fetchRobots() = %+v, want the previous robots.txt marked as not modified", robots)
+	}
+}
@@ -0,0 +1,156 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+
+	model "github.com/immz4/mindex/scraper/.gen/mindex/public/model"
+	. "github.com/immz4/mindex/scraper/.gen/mindex/public/table"
+)
+
+const crawlSchedulePrefix = "crawl-entity-"
+
+type ScheduleOptions struct {
+	Interval time.Duration
+	Jitter   time.Duration
+	Limits   *SitemapLimits
+}
+
+func CrawlScheduleID(entityID string) string {
+	return crawlSchedulePrefix + entityID
+}
+
+// SyncEntitySchedules makes sure every entity has a CrawlEntity schedule matching its current
+// URL and the requested interval. Schedules of disabled entities are paused instead of deleted so
+// their history is kept, and schedules left over from removed entities are deleted.
+func SyncEntitySchedules(ctx context.Context, temporalClient client.Client, db *sql.DB, options ScheduleOptions) error {
+	var entities []model.Entity
+	err := SELECT(Entity.AllColumns).
+		FROM(Entity).
+		ORDER_BY(Entity.CreatedAt.ASC()).
+		QueryContext(ctx, db, &entities)
+
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return fmt.Errorf("Failed to list entities: %s", err)
+	}
+
+	known := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		known[CrawlScheduleID(entity.ID.String())] = true
+
+		err = syncEntitySchedule(ctx, temporalClient, entity, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	schedules, err := temporalClient.ScheduleClient().List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to list schedules: %s", err)
+	}
+
+	for schedules.HasNext() {
+		schedule, err := schedules.Next()
+		if err != nil {
+			return fmt.Errorf("Failed to list schedules: %s", err)
+		}
+
+		if !strings.HasPrefix(schedule.ID, crawlSchedulePrefix) || known[schedule.ID] {
+			continue
+		}
+
+		err = temporalClient.ScheduleClient().GetHandle(ctx, schedule.ID).Delete(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to delete schedule %s: %s", schedule.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func crawlScheduleAction(entity model.Entity, options ScheduleOptions) *client.ScheduleWorkflowAction {
+	return &client.ScheduleWorkflowAction{
+		ID:       CrawlScheduleID(entity.ID.String()),
+		Workflow: CrawlEntity,
+		Args: []interface{}{CrawlEntityArgs{
+			EntityID: entity.ID.String(),
+			Url:      entity.URL,
+			Limits:   options.Limits,
+		}},
+		TaskQueue: ScraperQueueName,
+	}
+}
+
+func crawlScheduleSpec(options ScheduleOptions) client.ScheduleSpec {
+	return client.ScheduleSpec{
+		Intervals: []client.ScheduleIntervalSpec{{Every: options.Interval}},
+		Jitter:    options.Jitter,
+	}
+}
+
+func syncEntitySchedule(ctx context.Context, temporalClient client.Client, entity model.Entity, options ScheduleOptions) error {
+	scheduleID := CrawlScheduleID(entity.ID.String())
+	handle := temporalClient.ScheduleClient().GetHandle(ctx, scheduleID)
+
+	description, err := handle.Describe(ctx)
+
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		_, err = temporalClient.ScheduleClient().Create(ctx, client.ScheduleOptions{
+			ID:      scheduleID,
+			Spec:    crawlScheduleSpec(options),
+			Action:  crawlScheduleAction(entity, options),
+			Overlap: enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+			Paused:  !entity.Enabled,
+		})
+
+		if err != nil {
+			return fmt.Errorf("Failed to create schedule %s: %s", scheduleID, err)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to describe schedule %s: %s", scheduleID, err)
+	}
+
+	err = handle.Update(ctx, client.ScheduleUpdateOptions{
+		DoUpdate: func(input client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+			spec := crawlScheduleSpec(options)
+			schedule := input.Description.Schedule
+			schedule.Spec = &spec
+			schedule.Action = crawlScheduleAction(entity, options)
+
+			return &client.ScheduleUpdate{Schedule: &schedule}, nil
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to update schedule %s: %s", scheduleID, err)
+	}
+
+	paused := description.Schedule.State != nil && description.Schedule.State.Paused
+
+	if entity.Enabled && paused {
+		err = handle.Unpause(ctx, client.ScheduleUnpauseOptions{Note: "Entity enabled"})
+	} else if !entity.Enabled && !paused {
+		err = handle.Pause(ctx, client.SchedulePauseOptions{Note: "Entity disabled"})
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to toggle schedule %s: %s", scheduleID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/immz4/mindex/scraper"
+	"github.com/immz4/mindex/scraper/config"
+	"go.temporal.io/sdk/client"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to the YAML config, defaults to $"+config.PathEnv)
+	interval := flag.Duration("interval", 24*time.Hour, "how often every entity is recrawled")
+	jitter := flag.Duration("jitter", time.Hour, "random delay added to each scheduled crawl")
+	resync := flag.Duration("resync", 0, "keep running and resync schedules with the entity table at this interval")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalln("Unable to load config", err)
+	}
+
+	c, err := client.Dial(cfg.TemporalOptions())
+	if err != nil {
+		log.Fatalln("Unable to create Temporal client", err)
+	}
+	defer c.Close()
+
+	pgDb, err := cfg.OpenPostgres()
+	if err != nil {
+		log.Fatalln("Unable to open PG connection", err)
+	}
+	defer pgDb.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	options := scraper.ScheduleOptions{
+		Interval: *interval,
+		Jitter:   *jitter,
+	}
+
+	for {
+		err = scraper.SyncEntitySchedules(ctx, c, pgDb, options)
+		if err != nil {
+			log.Fatalln("Unable to sync entity schedules", err)
+		}
+
+		log.Println("Entity schedules synced")
+
+		if *resync <= 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*resync):
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+
+	"github.com/immz4/mindex/scraper/index"
+)
+
+// ClickHouseStore reads the tables written by index.Writer. FINAL collapses rows of documents
+// indexed more than once that have not been merged yet, and postings are only read for the
+// version of their document that is current.
+type ClickHouseStore struct {
+	DB *sql.DB
+}
+
+func (s *ClickHouseStore) Postings(ctx context.Context, terms []string) ([]Posting, error) {
+	set := clickhouse.GroupSet{Value: toAny(terms)}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT term, field, doc_id, term_freq FROM index_postings FINAL
+		WHERE term IN ? AND (doc_id, version) IN (
+			SELECT doc_id, crawled_at FROM index_documents FINAL
+			WHERE doc_id IN (SELECT doc_id FROM index_postings WHERE term IN ?)
+		)`,
+		set, set,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query postings: %s", err)
+	}
+	defer rows.Close()
+
+	var postings []Posting
+	for rows.Next() {
+		var posting Posting
+		err = rows.Scan(&posting.Term, &posting.Field, &posting.DocID, &posting.TermFreq)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read posting: %s", err)
+		}
+
+		postings = append(postings, posting)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read postings: %s", err)
+	}
+
+	return postings, nil
+}
+
+func (s *ClickHouseStore) Documents(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]Document, error) {
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT doc_id, entity_id, url, canonical_url, title, description, field_lengths FROM index_documents FINAL WHERE doc_id IN ?",
+		clickhouse.GroupSet{Value: values},
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query documents: %s", err)
+	}
+	defer rows.Close()
+
+	documents := make(map[uuid.UUID]Document, len(ids))
+	for rows.Next() {
+		var document Document
+		err = rows.Scan(&document.ID, &document.EntityID, &document.URL, &document.CanonicalURL, &document.Title, &document.Description, &document.FieldLengths)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read document: %s", err)
+		}
+
+		documents[document.ID] = document
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read documents: %s", err)
+	}
+
+	return documents, nil
+}
+
+func (s *ClickHouseStore) Stats(ctx context.Context) (Stats, error) {
+	columns := make([]string, 0, len(index.Fields))
+	for _, field := range index.Fields {
+		columns = append(columns, fmt.Sprintf("avg(field_lengths['%s'])", field))
+	}
+
+	row := s.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT count(), %s FROM index_documents FINAL", strings.Join(columns, ", ")))
+
+	var count uint64
+	averages := make([]float64, len(index.Fields))
+
+	dest := []any{&count}
+	for i := range averages {
+		dest = append(dest, &averages[i])
+	}
+
+	err := row.Scan(dest...)
+	if err != nil {
+		return Stats{}, fmt.Errorf("Failed to query index stats: %s", err)
+	}
+
+	stats := Stats{
+		Documents:       int(count),
+		AvgFieldLengths: make(map[string]float64, len(index.Fields)),
+	}
+
+	for i, field := range index.Fields {
+		if count > 0 {
+			stats.AvgFieldLengths[field] = averages[i]
+		}
+	}
+
+	return stats, nil
+}
+
+func toAny(values []string) []any {
+	converted := make([]any, 0, len(values))
+	for _, value := range values {
+		converted = append(converted, value)
+	}
+
+	return converted
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/immz4/mindex/scraper/index"
+)
+
+// MemoryIndex is a Store kept entirely in memory, for small corpora and for exercising the
+// engine without ClickHouse.
+type MemoryIndex struct {
+	mu        sync.RWMutex
+	postings  map[string][]Posting
+	documents map[uuid.UUID]Document
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings:  make(map[string][]Posting),
+		documents: make(map[uuid.UUID]Document),
+	}
+}
+
+// Add indexes doc, replacing any earlier version with the same ID.
+func (m *MemoryIndex) Add(doc index.Document) {
+	postings, lengths := index.Postings(doc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.documents[doc.ID]; ok {
+		m.remove(doc.ID)
+	}
+
+	for _, posting := range postings {
+		m.postings[posting.Term] = append(m.postings[posting.Term], Posting{
+			Term:     posting.Term,
+			Field:    posting.Field,
+			DocID:    posting.DocID,
+			TermFreq: uint32(len(posting.Positions)),
+		})
+	}
+
+	m.documents[doc.ID] = Document{
+		ID:           doc.ID,
+		EntityID:     doc.EntityID,
+		URL:          doc.URL,
+		CanonicalURL: doc.CanonicalURL,
+		Title:        doc.Title,
+		Description:  doc.Description,
+		FieldLengths: lengths,
+	}
+}
+
+func (m *MemoryIndex) Remove(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(id)
+}
+
+func (m *MemoryIndex) remove(id uuid.UUID) {
+	delete(m.documents, id)
+
+	for term, postings := range m.postings {
+		kept := postings[:0]
+		for _, posting := range postings {
+			if posting.DocID != id {
+				kept = append(kept, posting)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(m.postings, term)
+		} else {
+			m.postings[term] = kept
+		}
+	}
+}
+
+func (m *MemoryIndex) Postings(ctx context.Context, terms []string) ([]Posting, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var postings []Posting
+	for _, term := range terms {
+		postings = append(postings, m.postings[term]...)
+	}
+
+	return postings, nil
+}
+
+func (m *MemoryIndex) Documents(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	documents := make(map[uuid.UUID]Document, len(ids))
+	for _, id := range ids {
+		if document, ok := m.documents[id]; ok {
+			documents[id] = document
+		}
+	}
+
+	return documents, nil
+}
+
+func (m *MemoryIndex) Stats(ctx context.Context) (Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := Stats{
+		Documents:       len(m.documents),
+		AvgFieldLengths: make(map[string]float64, len(index.Fields)),
+	}
+
+	if stats.Documents == 0 {
+		return stats, nil
+	}
+
+	for _, document := range m.documents {
+		for field, length := range document.FieldLengths {
+			stats.AvgFieldLengths[field] += float64(length)
+		}
+	}
+
+	for field := range stats.AvgFieldLengths {
+		stats.AvgFieldLengths[field] /= float64(stats.Documents)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/immz4/mindex/scraper/index"
+)
+
+// Query matches documents containing any of Terms and none of Excluded. Terms are expected in
+// the form produced by index.Tokenize.
+type Query struct {
+	Terms    []string
+	Excluded []string
+}
+
+// ParseQuery builds a query from user input. Words prefixed with - are excluded, everything
+// else is tokenized like indexed text.
+func ParseQuery(raw string) Query {
+	var query Query
+	seen := make(map[string]bool)
+
+	for _, word := range strings.Fields(raw) {
+		excluded := false
+		if rest, ok := strings.CutPrefix(word, "-"); ok && rest != "" {
+			word, excluded = rest, true
+		}
+
+		for _, token := range index.Tokenize(word) {
+			key := token.Term
+			if excluded {
+				key = "-" + key
+			}
+
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if excluded {
+				query.Excluded = append(query.Excluded, token.Term)
+			} else {
+				query.Terms = append(query.Terms, token.Term)
+			}
+		}
+	}
+
+	return query
+}
@@ -0,0 +1,32 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Query
+	}{
+		{"postgres replication", Query{Terms: []string{"postgres", "replication"}}},
+		{"  Postgres\tREPLICATION ", Query{Terms: []string{"postgres", "replication"}}},
+		{"postgres -mysql", Query{Terms: []string{"postgres"}, Excluded: []string{"mysql"}}},
+		{"postgres -MySQL postgres -mysql", Query{Terms: []string{"postgres"}, Excluded: []string{"mysql"}}},
+		{"mysql -mysql", Query{Terms: []string{"mysql"}, Excluded: []string{"mysql"}}},
+		{"pg-replication", Query{Terms: []string{"pg", "replication"}}},
+		{"-pg-replication", Query{Excluded: []string{"pg", "replication"}}},
+		{"--mysql", Query{Excluded: []string{"mysql"}}},
+		{"postgres - mysql", Query{Terms: []string{"postgres", "mysql"}}},
+		{"c++ & c#", Query{Terms: []string{"c"}}},
+		{"", Query{}},
+	}
+
+	for _, test := range tests {
+		got := ParseQuery(test.raw)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", test.raw, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package search answers queries against the inverted index built by package index, ranking
+// matches with BM25F: term frequencies are length-normalized and boosted per field before
+// saturation, so a match in the title counts more than one in the body.
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/immz4/mindex/scraper/index"
+)
+
+const (
+	DefaultK1    = 1.2
+	DefaultB     = 0.75
+	DefaultLimit = 10
+)
+
+// DefaultBoosts weights fields by how strongly a match in them signals relevance.
+func DefaultBoosts() map[string]float64 {
+	return map[string]float64{
+		index.FieldTitle:       3,
+		index.FieldHeadings:    2,
+		index.FieldURL:         1.5,
+		index.FieldDescription: 1.5,
+		index.FieldContent:     1,
+	}
+}
+
+type Posting struct {
+	Term     string
+	Field    string
+	DocID    uuid.UUID
+	TermFreq uint32
+}
+
+type Document struct {
+	ID           uuid.UUID
+	EntityID     uuid.UUID
+	URL          string
+	CanonicalURL string
+	Title        string
+	Description  string
+	FieldLengths map[string]uint32
+}
+
+type Stats struct {
+	Documents int
+
+	// Average token count per field over all documents, counting missing fields as empty.
+	AvgFieldLengths map[string]float64
+}
+
+// Store is where the engine reads the index from.
+type Store interface {
+	// Postings returns every posting of the given terms.
+	Postings(ctx context.Context, terms []string) ([]Posting, error)
+	// Documents returns the stored documents with the given IDs; unknown IDs are left out.
+	Documents(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]Document, error)
+	Stats(ctx context.Context) (Stats, error)
+}
+
+type Result struct {
+	Document
+	Score float64
+}
+
+type Engine struct {
+	Store Store
+
+	K1     float64
+	B      float64
+	Boosts map[string]float64
+}
+
+// NewEngine returns an engine over store with the default ranking parameters.
+func NewEngine(store Store) *Engine {
+	return &Engine{
+		Store:  store,
+		K1:     DefaultK1,
+		B:      DefaultB,
+		Boosts: DefaultBoosts(),
+	}
+}
+
+// Search returns the limit best matches of query, best first. Fields without a boost are ignored,
+// and only the best match of every canonical URL is returned.
+func (e *Engine) Search(ctx context.Context, query Query, limit int) ([]Result, error) {
+	if len(query.Terms) == 0 {
+		return nil, errors.New("Query has no terms")
+	}
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	postings, err := e.Store.Postings(ctx, query.Terms)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[uuid.UUID]bool)
+	if len(query.Excluded) > 0 {
+		excludedPostings, err := e.Store.Postings(ctx, query.Excluded)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, posting := range excludedPostings {
+			excluded[posting.DocID] = true
+		}
+	}
+
+	// Document frequencies count every match, including documents filtered out below.
+	docFreqs := make(map[string]map[uuid.UUID]bool)
+
+	// term -> document -> field -> frequency
+	matches := make(map[string]map[uuid.UUID]map[string]uint32)
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+
+	for _, posting := range postings {
+		if docFreqs[posting.Term] == nil {
+			docFreqs[posting.Term] = make(map[uuid.UUID]bool)
+		}
+		docFreqs[posting.Term][posting.DocID] = true
+
+		if excluded[posting.DocID] || e.Boosts[posting.Field] == 0 {
+			continue
+		}
+
+		if matches[posting.Term] == nil {
+			matches[posting.Term] = make(map[uuid.UUID]map[string]uint32)
+		}
+
+		if matches[posting.Term][posting.DocID] == nil {
+			matches[posting.Term][posting.DocID] = make(map[string]uint32)
+		}
+
+		matches[posting.Term][posting.DocID][posting.Field] += posting.TermFreq
+
+		if !seen[posting.DocID] {
+			seen[posting.DocID] = true
+			ids = append(ids, posting.DocID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return []Result{}, nil
+	}
+
+	stats, err := e.Store.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := e.Store.Documents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[uuid.UUID]float64, len(documents))
+	for term, byDoc := range matches {
+		idf := e.idf(stats.Documents, len(docFreqs[term]))
+
+		for docID, fields := range byDoc {
+			document, ok := documents[docID]
+			if !ok {
+				continue
+			}
+
+			tf := e.weightedTermFreq(fields, document.FieldLengths, stats.AvgFieldLengths)
+			scores[docID] += idf * tf * (e.K1 + 1) / (tf + e.K1)
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for docID, score := range scores {
+		results = append(results, Result{Document: documents[docID], Score: score})
+	}
+
+	slices.SortFunc(results, func(a, b Result) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+
+			return 1
+		}
+
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	results = collapse(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// collapse keeps the first of the results sharing a canonical URL, so a page stored under
+// several documents is listed once.
+func collapse(results []Result) []Result {
+	seen := make(map[string]bool, len(results))
+	collapsed := results[:0]
+
+	for _, result := range results {
+		key := result.CanonicalURL
+		if key == "" {
+			key = result.URL
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		collapsed = append(collapsed, result)
+	}
+
+	return collapsed
+}
+
+// idf is the BM25 inverse document frequency, which stays positive for very common terms.
+func (e *Engine) idf(documents int, matching int) float64 {
+	return math.Log(1 + (float64(documents)-float64(matching)+0.5)/(float64(matching)+0.5))
+}
+
+// weightedTermFreq combines the per-field frequencies of one term in one document.
+func (e *Engine) weightedTermFreq(fields map[string]uint32, lengths map[string]uint32, avgLengths map[string]float64) float64 {
+	tf := 0.0
+	for field, freq := range fields {
+		norm := 1.0
+		if avg := avgLengths[field]; avg > 0 {
+			norm = 1 - e.B + e.B*float64(lengths[field])/avg
+		}
+
+		tf += e.Boosts[field] * float64(freq) / norm
+	}
+
+	return tf
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/immz4/mindex/scraper/index"
+)
+
+var (
+	docPostgres = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	docMySQL    = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	docPasta    = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+)
+
+func testDocument(id uuid.UUID, url string, title string, content string) index.Document {
+	return index.Document{
+		ID:           id,
+		URL:          url,
+		CanonicalURL: url,
+		Title:        title,
+		Fields: map[string]string{
+			index.FieldTitle:   title,
+			index.FieldContent: content,
+		},
+	}
+}
+
+// testIndex holds three documents whose title lengths match and content lengths differ, so
+// the expected scores below exercise both field boosts and length normalization.
+func testIndex() *MemoryIndex {
+	memory := NewMemoryIndex()
+	memory.Add(testDocument(docPostgres, "https://example.com/postgres", "Postgres replication", "Postgres streaming replication guide"))
+	memory.Add(testDocument(docMySQL, "https://example.com/mysql", "MySQL replication", "MySQL replication setup"))
+	memory.Add(testDocument(docPasta, "https://example.com/pasta", "Cooking pasta", "Boil water, add pasta"))
+
+	return memory
+}
+
+type expectedResult struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+func checkResults(t *testing.T, results []Result, want []expectedResult) {
+	t.Helper()
+
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+
+	for i, result := range results {
+		if result.ID != want[i].ID {
+			t.Errorf("result %d is %s, want %s", i, result.ID, want[i].ID)
+		}
+
+		if math.Abs(result.Score-want[i].Score) > 1e-9 {
+			t.Errorf("result %d scores %.12f, want %.12f", i, result.Score, want[i].Score)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	engine := NewEngine(testIndex())
+
+	// Scores follow from N = 3, k1 = 1.2, b = 0.75, title boost 3 and content boost 1, with
+	// average lengths of 2 title and 11/3 content tokens.
+	tests := []struct {
+		query string
+		want  []expectedResult
+	}{
+		// Both match in title and content once; the shorter content of the MySQL page wins.
+		{"replication", []expectedResult{
+			{docMySQL, 0.802422699046256},
+			{docPostgres, 0.7924253401119072},
+		}},
+		{"postgres replication", []expectedResult{
+			{docPostgres, 2.446101835454793},
+			{docMySQL, 0.802422699046256},
+		}},
+		// Excluded documents still count towards document frequency.
+		{"replication -mysql", []expectedResult{
+			{docPostgres, 0.7924253401119072},
+		}},
+		{"pasta", []expectedResult{
+			{docPasta, 1.6536764953428857},
+		}},
+		{"MYSQL", []expectedResult{
+			{docMySQL, 1.6745395301909434},
+		}},
+		{"kubernetes", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			results, err := engine.Search(context.Background(), ParseQuery(test.query), 10)
+			if err != nil {
+				t.Fatalf("Search() error = %s", err)
+			}
+
+			checkResults(t, results, test.want)
+		})
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	engine := NewEngine(testIndex())
+
+	results, err := engine.Search(context.Background(), ParseQuery("postgres replication"), 1)
+	if err != nil {
+		t.Fatalf("Search() error = %s", err)
+	}
+
+	checkResults(t, results, []expectedResult{{docPostgres, 2.446101835454793}})
+}
+
+func TestSearchWithoutTerms(t *testing.T) {
+	engine := NewEngine(testIndex())
+
+	_, err := engine.Search(context.Background(), ParseQuery("-mysql"), 10)
+	if err == nil {
+		t.Error("Search() without terms succeeded")
+	}
+}
+
+func TestSearchIgnoresUnboostedFields(t *testing.T) {
+	engine := NewEngine(testIndex())
+	engine.Boosts = map[string]float64{index.FieldContent: 1}
+
+	results, err := engine.Search(context.Background(), ParseQuery("cooking"), 10)
+	if err != nil {
+		t.Fatalf("Search() error = %s", err)
+	}
+
+	if len(results) != 0 {
+		t.Errorf("Search() matched the unboosted title: %+v", results)
+	}
+}
+
+func TestSearchBreaksTiesByID(t *testing.T) {
+	first := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	second := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+	memory := NewMemoryIndex()
+	memory.Add(testDocument(second, "https://example.org/b", "Replication", "Replication"))
+	memory.Add(testDocument(first, "https://example.org/a", "Replication", "Replication"))
+	memory.Add(testDocument(docPasta, "https://example.org/pasta", "Pasta", "Pasta"))
+
+	results, err := NewEngine(memory).Search(context.Background(), ParseQuery("replication"), 10)
+	if err != nil {
+		t.Fatalf("Search() error = %s", err)
+	}
+
+	if len(results) != 2 || results[0].ID != first || results[1].ID != second {
+		t.Errorf("Search() = %+v, want %s before %s", results, first, second)
+	}
+}
+
+func TestSearchCollapsesCanonicalURLs(t *testing.T) {
+	memory := testIndex()
+
+	// The same page indexed for another entity, with a weaker match.
+	duplicate := testDocument(uuid.MustParse("00000000-0000-0000-0000-0000000000ff"),
+		"http://www.example.com/postgres", "Postgres", "Postgres replication notes and more")
+	duplicate.CanonicalURL = "https://example.com/postgres"
+	memory.Add(duplicate)
+
+	results, err := NewEngine(memory).Search(context.Background(), ParseQuery("postgres replication"), 2)
+	if err != nil {
+		t.Fatalf("Search() error = %s", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+
+	if results[0].ID != docPostgres || results[1].ID != docMySQL {
+		t.Errorf("Search() = %s, %s, want %s, %s", results[0].ID, results[1].ID, docPostgres, docMySQL)
+	}
+}
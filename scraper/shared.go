@@ -1,3 +1,79 @@
 package scraper
 
+import "time"
+
 const ScraperQueueName = "scraper-queue"
+
+// InvalidURLErrorType marks URLs that cannot be normalized, retrying does not help.
+const InvalidURLErrorType = "InvalidURL"
+
+const (
+	DefaultSitemapMaxDepth      = 5
+	DefaultSitemapMaxConcurrent = 10
+	DefaultSitemapURLBudget     = 1_000_000
+)
+
+const SitemapIndexPageSize = 500
+
+// SitemapChildrenPerRun bounds how many children a sitemap index starts before it continues as
+// new, keeping its history well below the Temporal limit.
+const SitemapChildrenPerRun = 1000
+
+// MaxSitemapSize is the uncompressed size limit set by the sitemaps protocol.
+const MaxSitemapSize = 50 * 1024 * 1024
+
+const (
+	DefaultPageBatchSize     = 100
+	DefaultPageMaxConcurrent = 10
+	PageBatchesPerRun        = 50
+	MaxPageSize              = 10 * 1024 * 1024
+
+	// FetchPageTimeout leaves room for MaxHostWait on top of the request itself.
+	FetchPageTimeout = 2 * MaxHostWait
+	// MaxHostWaitDeferrals is how often a page is put back after a RateLimitedErrorType
+	// error before the fetch counts as failed.
+	MaxHostWaitDeferrals = 20
+
+	// MaxLinkDepth bounds how many links away from a sitemap entry pages are still enqueued.
+	MaxLinkDepth = 3
+)
+
+const (
+	DefaultIndexBatchSize = 100
+	IndexActivityTimeout  = 5 * time.Minute
+)
+
+const (
+	DefaultCrawlDelay = time.Second
+	MaxCrawlDelay     = 30 * time.Second
+	CrawlDelayTTL     = 24 * time.Hour
+
+	// MaxHostWait bounds how long an activity queues for a host before it hands the wait
+	// back to its workflow as a RateLimitedErrorType error.
+	MaxHostWait       = 2 * MaxCrawlDelay
+	HostWaitHeartbeat = 10 * time.Second
+)
+
+// RateLimitedErrorType marks activities that gave up their turn at a busy host. The error
+// details hold the time.Duration to wait before trying again.
+const RateLimitedErrorType = "RateLimited"
+
+const (
+	MaxRedirects       = 10
+	MaxRobotsRedirects = 5
+	MaxRobotsSize      = 500 * 1024
+	RobotsRetryWindow  = 30 * time.Minute
+)
+
+const (
+	// SitemapChunkSize is how many parsed entries go into one payload.
+	SitemapChunkSize      = 5000
+	SitemapHeartbeatEvery = 500
+
+	SitemapActivityTimeout  = 10 * time.Minute
+	SitemapHeartbeatTimeout = time.Minute
+
+	// SitemapProgressInterval is how often CrawlEntity counts the sitemaps saved so far, for the
+	// progress query.
+	SitemapProgressInterval = time.Minute
+)
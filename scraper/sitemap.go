@@ -0,0 +1,419 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/log"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	SitemapFormatXML  = "xml"
+	SitemapFormatText = "txt"
+
+	SitemapCompressionGzip = "gzip"
+
+	// SitemapStatusErrorType marks sitemaps answered with a status other than 2xx or 304.
+	// Client errors are not retried, except for 408 and 429.
+	SitemapStatusErrorType = "SitemapStatus"
+)
+
+// Values allowed for <changefreq> by the sitemaps protocol.
+var sitemapChangeFreqs = []string{"always", "hourly", "daily", "weekly", "monthly", "yearly", "never"}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+type sitemapIndexSitemap struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// w3cDateLayouts covers the W3C Datetime profile used by <lastmod>, plus the variants sites
+// actually emit: a missing timezone (read as UTC), a space instead of "T" and offsets without
+// a colon.
+var w3cDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// maxLoggedInvalidDates caps data-quality warnings per sitemap; the rest are only counted.
+const maxLoggedInvalidDates = 10
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// openSitemap sniffs the body instead of trusting Content-Type, because sitemap.xml.gz files
+// are served as anything from application/x-gzip to text/xml. Compressed bodies are
+// transparently decompressed and the returned reader is capped at MaxSitemapSize.
+func openSitemap(body io.Reader) (reader io.Reader, format string, compression string, err error) {
+	buffered := bufio.NewReader(body)
+
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", "", fmt.Errorf("Failed to read sitemap: %s", err)
+	}
+
+	reader = buffered
+	if bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("Failed to decompress sitemap: %s", err)
+		}
+
+		compression = SitemapCompressionGzip
+		buffered = bufio.NewReader(gzipReader)
+		reader = buffered
+	}
+
+	reader = io.LimitReader(reader, MaxSitemapSize)
+
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, "", "", fmt.Errorf("Failed to read sitemap: %s", err)
+	}
+
+	head = bytes.TrimPrefix(head, utf8BOM)
+	head = bytes.TrimLeft(head, " \t\r\n")
+
+	if bytes.HasPrefix(head, []byte("<")) {
+		return reader, SitemapFormatXML, compression, nil
+	}
+
+	return reader, SitemapFormatText, compression, nil
+}
+
+// parseTextSitemap reads the plain text format: one absolute URL per line.
+// Lines that are not http(s) URLs are skipped rather than failing the whole sitemap.
+func parseTextSitemap(reader io.Reader, chunker *sitemapChunker) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), string(utf8BOM)))
+		if line == "" {
+			continue
+		}
+
+		location, err := url.Parse(line)
+		if err != nil || (location.Scheme != "http" && location.Scheme != "https") || location.Host == "" {
+			continue
+		}
+
+		err = chunker.addUrlset(SitemapResUrlset{
+			Location: line,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Failed to read text sitemap: %s", err)
+	}
+
+	return nil
+}
+
+// xmlRootElement returns the local name of the first element in the document, leaving the
+// decoder positioned right after it.
+func xmlRootElement(decoder *xml.Decoder) (string, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("Failed to find sitemap root element: %s", err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// decodeXMLElements calls decode for every element called name. decode is expected to consume
+// the element with DecodeElement, so only one entry is held in memory at a time.
+func decodeXMLElements(decoder *xml.Decoder, name string, decode func(start xml.StartElement) error) error {
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse sitemap: %s", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != name {
+			continue
+		}
+
+		err = decode(start)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseXMLSitemap detects the kind of document from its root element and streams its entries
+// into chunker in a single pass. It returns the detected format.
+func parseXMLSitemap(reader io.Reader, chunker *sitemapChunker) (string, error) {
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	rootElement, err := xmlRootElement(decoder)
+	if err != nil {
+		return "", err
+	}
+
+	switch rootElement {
+	case "urlset":
+		return SitemapFormatXML, decodeXMLElements(decoder, "url", func(start xml.StartElement) error {
+			var entry sitemapURL
+			err := decoder.DecodeElement(&entry, &start)
+			if err != nil {
+				return fmt.Errorf("Failed to parse sitemap url: %s", err)
+			}
+
+			location := strings.TrimSpace(entry.Loc)
+			if location == "" {
+				return nil
+			}
+
+			return chunker.addUrlset(SitemapResUrlset{
+				Location:        location,
+				LastModified:    chunker.parseDate(location, entry.LastMod, w3cDateLayouts),
+				ChangeFrequency: parseChangeFreq(entry.ChangeFreq),
+				Priority:        parsePriority(entry.Priority),
+			})
+		})
+	case "sitemapindex":
+		return SitemapFormatXML, decodeXMLElements(decoder, "sitemap", func(start xml.StartElement) error {
+			var entry sitemapIndexSitemap
+			err := decoder.DecodeElement(&entry, &start)
+			if err != nil {
+				return fmt.Errorf("Failed to parse sitemap index entry: %s", err)
+			}
+
+			location := strings.TrimSpace(entry.Loc)
+			if location == "" {
+				return nil
+			}
+
+			return chunker.addIndex(SitemapResIndex{
+				Location:     location,
+				LastModified: chunker.parseDate(location, entry.LastMod, w3cDateLayouts),
+			})
+		})
+	case "rss":
+		return SitemapFormatRSS, decodeXMLElements(decoder, "item", func(start xml.StartElement) error {
+			var item rssItem
+			err := decoder.DecodeElement(&item, &start)
+			if err != nil {
+				return fmt.Errorf("Failed to parse RSS item: %s", err)
+			}
+
+			location, date := rssItemLink(item)
+			if location == "" {
+				return nil
+			}
+
+			return chunker.addUrlset(SitemapResUrlset{
+				Location:     location,
+				LastModified: chunker.parseDate(location, date, rssDateLayouts),
+			})
+		})
+	case "feed":
+		return SitemapFormatAtom, decodeXMLElements(decoder, "entry", func(start xml.StartElement) error {
+			var atom atomEntry
+			err := decoder.DecodeElement(&atom, &start)
+			if err != nil {
+				return fmt.Errorf("Failed to parse Atom entry: %s", err)
+			}
+
+			location, date := atomEntryLink(atom)
+			if location == "" {
+				return nil
+			}
+
+			return chunker.addUrlset(SitemapResUrlset{
+				Location:     location,
+				LastModified: chunker.parseDate(location, date, atomDateLayouts),
+			})
+		})
+	default:
+		return "", fmt.Errorf("Unknown sitemap root element %s", rootElement)
+	}
+}
+
+// parseSitemapDate parses value with the first matching layout. An empty value is not an
+// error, it just means the date is unknown.
+func parseSitemapDate(value string, layouts []string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range layouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			parsed = parsed.UTC()
+			return &parsed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unrecognized date %q", value)
+}
+
+// parseChangeFreq returns the normalized <changefreq> value, or an empty string for values
+// outside the protocol enum.
+func parseChangeFreq(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if slices.Contains(sitemapChangeFreqs, value) {
+		return value
+	}
+
+	return ""
+}
+
+// parsePriority returns <priority> if it is a number between 0.0 and 1.0.
+func parsePriority(value string) *float64 {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(priority) || priority < 0 || priority > 1 {
+		return nil
+	}
+
+	return &priority
+}
+
+// sitemapChunker writes parsed entries to the payload store every size entries, so memory
+// is bounded by the chunk size instead of the sitemap size. A document is either an urlset
+// or an index, never both.
+type sitemapChunker struct {
+	ctx       context.Context
+	store     PayloadStore
+	scope     string
+	size      int
+	logger    log.Logger
+	heartbeat func(count int)
+
+	urlset  []SitemapResUrlset
+	index   []SitemapResIndex
+	keys    []string
+	count   int
+	indexed bool
+
+	invalidDates int
+}
+
+func newSitemapChunker(ctx context.Context, store PayloadStore, scope string, logger log.Logger, heartbeat func(count int)) *sitemapChunker {
+	return &sitemapChunker{
+		ctx:       ctx,
+		store:     store,
+		scope:     scope,
+		size:      SitemapChunkSize,
+		logger:    logger,
+		heartbeat: heartbeat,
+	}
+}
+
+// parseDate parses a date of the entry at location. Unparseable dates are stored as unknown
+// and reported as a data-quality issue rather than failing the sitemap.
+func (c *sitemapChunker) parseDate(location string, value string, layouts []string) *time.Time {
+	parsed, err := parseSitemapDate(value, layouts)
+	if err == nil {
+		return parsed
+	}
+
+	c.invalidDates++
+	if c.logger != nil && c.invalidDates <= maxLoggedInvalidDates {
+		c.logger.Warn("Unparseable sitemap date", "url", location, "error", err)
+	}
+
+	return nil
+}
+
+func (c *sitemapChunker) added() error {
+	c.count++
+
+	if c.count%SitemapHeartbeatEvery == 0 && c.heartbeat != nil {
+		c.heartbeat(c.count)
+	}
+
+	if len(c.urlset)+len(c.index) < c.size {
+		return nil
+	}
+
+	return c.flush()
+}
+
+func (c *sitemapChunker) addUrlset(entry SitemapResUrlset) error {
+	c.urlset = append(c.urlset, entry)
+	return c.added()
+}
+
+func (c *sitemapChunker) addIndex(entry SitemapResIndex) error {
+	c.indexed = true
+	c.index = append(c.index, entry)
+	return c.added()
+}
+
+func (c *sitemapChunker) flush() error {
+	var data []byte
+	var err error
+
+	switch {
+	case len(c.index) > 0:
+		data, err = json.Marshal(SitemapIndexParsed{Index: c.index})
+	case len(c.urlset) > 0:
+		data, err = json.Marshal(SitemapUrlsetParsed{Urlset: c.urlset})
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode sitemap chunk: %s", err)
+	}
+
+	key, err := c.store.Put(c.ctx, c.scope, data)
+	if err != nil {
+		return err
+	}
+
+	c.keys = append(c.keys, key)
+	c.urlset = c.urlset[:0]
+	c.index = c.index[:0]
+
+	return nil
+}
@@ -0,0 +1,467 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Failed to compress: %s", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to compress: %s", err)
+	}
+
+	return buffer.Bytes()
+}
+
+// repeatReader endlessly returns the same byte.
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+
+	return len(p), nil
+}
+
+type parsedTestSitemap struct {
+	format  string
+	urlset  []SitemapResUrlset
+	index   []SitemapResIndex
+	chunker *sitemapChunker
+}
+
+// parseTestSitemap runs body through the same steps as GetSitemap and reads back the chunks.
+func parseTestSitemap(t *testing.T, body string, chunkSize int) (parsedTestSitemap, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := &FilePayloadStore{Dir: t.TempDir()}
+	chunker := newSitemapChunker(ctx, store, t.Name(), nil, nil)
+	if chunkSize > 0 {
+		chunker.size = chunkSize
+	}
+
+	reader, format, _, err := openSitemap(strings.NewReader(body))
+	if err != nil {
+		return parsedTestSitemap{}, err
+	}
+
+	if format == SitemapFormatXML {
+		format, err = parseXMLSitemap(reader, chunker)
+	} else {
+		err = parseTextSitemap(reader, chunker)
+	}
+
+	if err != nil {
+		return parsedTestSitemap{}, err
+	}
+
+	err = chunker.flush()
+	if err != nil {
+		t.Fatalf("flush() error = %s", err)
+	}
+
+	parsed := parsedTestSitemap{format: format, chunker: chunker}
+	for _, key := range chunker.keys {
+		data, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get() error = %s", err)
+		}
+
+		var chunk struct {
+			SitemapUrlsetParsed
+			SitemapIndexParsed
+		}
+
+		err = json.Unmarshal(data, &chunk)
+		if err != nil {
+			t.Fatalf("Failed to decode chunk: %s", err)
+		}
+
+		parsed.urlset = append(parsed.urlset, chunk.Urlset...)
+		parsed.index = append(parsed.index, chunk.Index...)
+	}
+
+	return parsed, nil
+}
+
+func date(value string) *time.Time {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return &parsed
+}
+
+func priority(value float64) *float64 {
+	return &value
+}
+
+func TestOpenSitemap(t *testing.T) {
+	xmlBody := []byte(`<?xml version="1.0"?><urlset></urlset>`)
+	textBody := []byte("https://example.com/a\nhttps://example.com/b\n")
+
+	tests := []struct {
+		name            string
+		body            []byte
+		wantFormat      string
+		wantCompression string
+		wantBody        []byte
+	}{
+		{"xml", xmlBody, SitemapFormatXML, "", xmlBody},
+		{"xml after bom and whitespace", append([]byte("\xef\xbb\xbf \r\n\t"), xmlBody...), SitemapFormatXML, "", append([]byte("\xef\xbb\xbf \r\n\t"), xmlBody...)},
+		{"text", textBody, SitemapFormatText, "", textBody},
+		{"empty", nil, SitemapFormatText, "", nil},
+		{"gzipped xml", gzipped(t, xmlBody), SitemapFormatXML, SitemapCompressionGzip, xmlBody},
+		{"gzipped text", gzipped(t, textBody), SitemapFormatText, SitemapCompressionGzip, textBody},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, format, compression, err := openSitemap(bytes.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("openSitemap() error = %s", err)
+			}
+
+			if format != test.wantFormat || compression != test.wantCompression {
+				t.Errorf("openSitemap() = %q, %q, want %q, %q", format, compression, test.wantFormat, test.wantCompression)
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read sitemap: %s", err)
+			}
+
+			// Sniffing must not consume anything, decompressed or not.
+			if !bytes.Equal(got, test.wantBody) {
+				t.Errorf("openSitemap() read %q, want %q", got, test.wantBody)
+			}
+		})
+	}
+
+	t.Run("broken gzip", func(t *testing.T) {
+		_, _, _, err := openSitemap(bytes.NewReader([]byte{0x1f, 0x8b, 0x00}))
+		if err == nil {
+			t.Error("openSitemap() succeeded on a broken gzip header")
+		}
+	})
+}
+
+func TestOpenSitemapSizeLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		body func() io.Reader
+	}{
+		{"plain", func() io.Reader {
+			return io.LimitReader(repeatReader(' '), MaxSitemapSize+1024)
+		}},
+		{"decompressed", func() io.Reader {
+			data, err := io.ReadAll(io.LimitReader(repeatReader(' '), MaxSitemapSize+1024))
+			if err != nil {
+				t.Fatalf("Failed to build sitemap: %s", err)
+			}
+
+			return bytes.NewReader(gzipped(t, data))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, _, _, err := openSitemap(test.body())
+			if err != nil {
+				t.Fatalf("openSitemap() error = %s", err)
+			}
+
+			read, err := io.Copy(io.Discard, reader)
+			if err != nil {
+				t.Fatalf("Failed to read sitemap: %s", err)
+			}
+
+			if read != MaxSitemapSize {
+				t.Errorf("openSitemap() read %d bytes, want %d", read, MaxSitemapSize)
+			}
+		})
+	}
+}
+
+func TestParseTextSitemap(t *testing.T) {
+	body := "\xef\xbb\xbfhttps://example.com/a\n" +
+		"\n" +
+		"   http://example.com/b  \r\n" +
+		"/relative\n" +
+		"ftp://example.com/file\n" +
+		"not a url\n" +
+		"https://\n" +
+		"https://example.com/c"
+
+	parsed, err := parseTestSitemap(t, body, 0)
+	if err != nil {
+		t.Fatalf("parseTextSitemap() error = %s", err)
+	}
+
+	want := []SitemapResUrlset{
+		{Location: "https://example.com/a"},
+		{Location: "http://example.com/b"},
+		{Location: "https://example.com/c"},
+	}
+
+	if parsed.format != SitemapFormatText || !reflect.DeepEqual(parsed.urlset, want) {
+		t.Errorf("parseTextSitemap() = %s %+v, want %s %+v", parsed.format, parsed.urlset, SitemapFormatText, want)
+	}
+}
+
+func TestParseXMLSitemap(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantFormat string
+		wantUrlset []SitemapResUrlset
+		wantIndex  []SitemapResIndex
+	}{
+		{
+			name: "urlset",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url>
+						<loc> https://example.com/a </loc>
+						<lastmod>2024-05-01T10:00:00+02:00</lastmod>
+						<changefreq>Daily</changefreq>
+						<priority>0.8</priority>
+					</url>
+					<url><loc>https://example.com/b</loc><changefreq>sometimes</changefreq><priority>1.5</priority></url>
+					<url><lastmod>2024-05-01</lastmod></url>
+				</urlset>`,
+			wantFormat: SitemapFormatXML,
+			wantUrlset: []SitemapResUrlset{
+				{Location: "https://example.com/a", LastModified: date("2024-05-01T08:00:00Z"), ChangeFrequency: "daily", Priority: priority(0.8)},
+				{Location: "https://example.com/b"},
+			},
+		},
+		{
+			name: "sitemap index",
+			body: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<sitemap><loc>https://example.com/sitemap-1.xml.gz</loc><lastmod>2024-05-01</lastmod></sitemap>
+					<sitemap><loc>https://example.com/sitemap-2.xml</loc></sitemap>
+				</sitemapindex>`,
+			wantFormat: SitemapFormatXML,
+			wantIndex: []SitemapResIndex{
+				{Location: "https://example.com/sitemap-1.xml.gz", LastModified: date("2024-05-01T00:00:00Z")},
+				{Location: "https://example.com/sitemap-2.xml"},
+			},
+		},
+		{
+			name:       "latin-1 encoding",
+			body:       "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><urlset><url><loc>https://example.com/caf\xe9</loc></url></urlset>",
+			wantFormat: SitemapFormatXML,
+			wantUrlset: []SitemapResUrlset{{Location: "https://example.com/café"}},
+		},
+		{
+			name: "rss",
+			body: `<rss version="2.0"><channel>
+					<item><link>https://example.com/post-1</link><pubDate>Wed, 01 May 2024 10:00:00 +0200</pubDate></item>
+					<item><guid>https://example.com/post-2</guid><pubDate>1 May 2024 10:00:00 GMT</pubDate></item>
+					<item><guid isPermaLink="false">post-3</guid></item>
+				</channel></rss>`,
+			wantFormat: SitemapFormatRSS,
+			wantUrlset: []SitemapResUrlset{
+				{Location: "https://example.com/post-1", LastModified: date("2024-05-01T08:00:00Z")},
+				{Location: "https://example.com/post-2", LastModified: date("2024-05-01T10:00:00Z")},
+			},
+		},
+		{
+			name: "atom",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+					<entry>
+						<link rel="self" href="https://example.com/feed/1"/>
+						<link rel="alternate" href="https://example.com/post-1"/>
+						<updated>2024-05-01T10:00:00Z</updated>
+						<published>2024-04-01T10:00:00Z</published>
+					</entry>
+					<entry><link href="https://example.com/post-2"/><published>2024-04-01T10:00:00.5Z</published></entry>
+					<entry><link rel="self" href="https://example.com/feed/3"/></entry>
+				</feed>`,
+			wantFormat: SitemapFormatAtom,
+			wantUrlset: []SitemapResUrlset{
+				{Location: "https://example.com/post-1", LastModified: date("2024-05-01T10:00:00Z")},
+				{Location: "https://example.com/post-2", LastModified: date("2024-04-01T10:00:00.5Z")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := parseTestSitemap(t, test.body, 0)
+			if err != nil {
+				t.Fatalf("parseXMLSitemap() error = %s", err)
+			}
+
+			if parsed.format != test.wantFormat {
+				t.Errorf("parseXMLSitemap() format = %s, want %s", parsed.format, test.wantFormat)
+			}
+
+			if !reflect.DeepEqual(parsed.urlset, test.wantUrlset) {
+				t.Errorf("parseXMLSitemap() urlset = %+v, want %+v", parsed.urlset, test.wantUrlset)
+			}
+
+			if !reflect.DeepEqual(parsed.index, test.wantIndex) {
+				t.Errorf("parseXMLSitemap() index = %+v, want %+v", parsed.index, test.wantIndex)
+			}
+		})
+	}
+}
+
+func TestParseXMLSitemapErrors(t *testing.T) {
+	for _, body := range []string{
+		`<html><body>Not found</body></html>`,
+		`<?xml version="1.0"?>`,
+	} {
+		if _, err := parseTestSitemap(t, body, 0); err == nil {
+			t.Errorf("parseXMLSitemap(%q) succeeded, want an error", body)
+		}
+	}
+}
+
+func TestParseXMLSitemapInvalidDates(t *testing.T) {
+	parsed, err := parseTestSitemap(t, `<urlset>
+			<url><loc>https://example.com/a</loc><lastmod>yesterday</lastmod></url>
+			<url><loc>https://example.com/b</loc><lastmod>2024-13-01</lastmod></url>
+			<url><loc>https://example.com/c</loc><lastmod>2024-05-01</lastmod></url>
+		</urlset>`, 0)
+	if err != nil {
+		t.Fatalf("parseXMLSitemap() error = %s", err)
+	}
+
+	if len(parsed.urlset) != 3 || parsed.urlset[0].LastModified != nil || parsed.urlset[1].LastModified != nil {
+		t.Errorf("parseXMLSitemap() urlset = %+v, want every entry with unknown invalid dates", parsed.urlset)
+	}
+
+	if parsed.chunker.invalidDates != 2 {
+		t.Errorf("parseXMLSitemap() counted %d invalid dates, want 2", parsed.chunker.invalidDates)
+	}
+}
+
+func TestSitemapChunks(t *testing.T) {
+	var body strings.Builder
+	for i := range 5 {
+		body.WriteString("https://example.com/")
+		body.WriteByte(byte('a' + i))
+		body.WriteByte('\n')
+	}
+
+	parsed, err := parseTestSitemap(t, body.String(), 2)
+	if err != nil {
+		t.Fatalf("parseTextSitemap() error = %s", err)
+	}
+
+	if len(parsed.chunker.keys) != 3 || len(parsed.urlset) != 5 {
+		t.Errorf("parseTextSitemap() = %d chunks with %d urls, want 3 chunks with 5 urls", len(parsed.chunker.keys), len(parsed.urlset))
+	}
+}
+
+func TestParseSitemapDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *time.Time
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"2024-05-01T10:20:30Z", date("2024-05-01T10:20:30Z")},
+		{"2024-05-01T10:20:30.123+02:00", date("2024-05-01T08:20:30.123Z")},
+		{"2024-05-01T10:20+02:00", date("2024-05-01T08:20:00Z")},
+		{"2024-05-01T10:20:30+0200", date("2024-05-01T08:20:30Z")},
+		{"2024-05-01T10:20-0130", date("2024-05-01T11:50:00Z")},
+		{"2024-05-01T10:20:30", date("2024-05-01T10:20:30Z")},
+		{"2024-05-01T10:20", date("2024-05-01T10:20:00Z")},
+		{"2024-05-01 10:20:30+02:00", date("2024-05-01T08:20:30Z")},
+		{"2024-05-01 10:20:30+0200", date("2024-05-01T08:20:30Z")},
+		{"2024-05-01 10:20:30", date("2024-05-01T10:20:30Z")},
+		{"2024-05-01 10:20", date("2024-05-01T10:20:00Z")},
+		{" 2024-05-01 ", date("2024-05-01T00:00:00Z")},
+		{"2024-05", date("2024-05-01T00:00:00Z")},
+		{"2024", date("2024-01-01T00:00:00Z")},
+	}
+
+	for _, test := range tests {
+		got, err := parseSitemapDate(test.value, w3cDateLayouts)
+		if err != nil {
+			t.Errorf("parseSitemapDate(%q) error = %s", test.value, err)
+			continue
+		}
+
+		if (got == nil) != (test.want == nil) || (got != nil && !got.Equal(*test.want)) {
+			t.Errorf("parseSitemapDate(%q) = %v, want %v", test.value, got, test.want)
+		}
+
+		if got != nil && got.Location() != time.UTC {
+			t.Errorf("parseSitemapDate(%q) is in %s, want UTC", test.value, got.Location())
+		}
+	}
+
+	for _, value := range []string{"yesterday", "01/05/2024", "2024-13-01", "2024-05-01T25:00:00Z", "May 1, 2024"} {
+		if got, err := parseSitemapDate(value, w3cDateLayouts); err == nil {
+			t.Errorf("parseSitemapDate(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestParseChangeFreq(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"daily", "daily"},
+		{" Weekly ", "weekly"},
+		{"NEVER", "never"},
+		{"", ""},
+		{"sometimes", ""},
+		{"every day", ""},
+	}
+
+	for _, test := range tests {
+		if got := parseChangeFreq(test.value); got != test.want {
+			t.Errorf("parseChangeFreq(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *float64
+	}{
+		{"0.5", priority(0.5)},
+		{" 1.0 ", priority(1)},
+		{"0", priority(0)},
+		{".3", priority(0.3)},
+		{"", nil},
+		{"high", nil},
+		{"1.1", nil},
+		{"-0.1", nil},
+		{"NaN", nil},
+		{"Inf", nil},
+	}
+
+	for _, test := range tests {
+		got := parsePriority(test.value)
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("parsePriority(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package urlnorm canonicalizes crawl URLs so that different spellings of the same address
+// are stored and fetched once.
+package urlnorm
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultStripParams are query parameters that only track where a visitor came from.
+// A trailing * matches any parameter with that prefix.
+var DefaultStripParams = []string{
+	"utm_*",
+	"gclid",
+	"gclsrc",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"fbclid",
+	"msclkid",
+	"yclid",
+	"twclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"oly_anon_id",
+	"oly_enc_id",
+	"vero_id",
+}
+
+// DefaultScheme is the scheme http and https URLs are canonicalized to when Rules.Scheme is empty.
+const DefaultScheme = "https"
+
+// Rules adjust the parameters stripped for one entity. KeepParams wins over both StripParams
+// and DefaultStripParams, for sites where a parameter in the default list selects content.
+//
+// Scheme is the scheme both http and https URLs are canonicalized to, so the two spellings of a
+// URL dedupe. It comes from the entity URL and is not stored with the rules.
+type Rules struct {
+	StripParams []string `json:"strip_params,omitempty"`
+	KeepParams  []string `json:"keep_params,omitempty"`
+	Scheme      string   `json:"-"`
+}
+
+type Normalizer struct {
+	strip  []string
+	keep   []string
+	scheme string
+}
+
+func New(rules Rules) *Normalizer {
+	scheme := strings.ToLower(rules.Scheme)
+	if scheme != "http" && scheme != "https" {
+		scheme = DefaultScheme
+	}
+
+	return &Normalizer{
+		strip:  lowerAll(slices.Concat(DefaultStripParams, rules.StripParams)),
+		keep:   lowerAll(rules.KeepParams),
+		scheme: scheme,
+	}
+}
+
+var defaultNormalizer = New(Rules{})
+
+// Normalize canonicalizes raw with the default rules.
+func Normalize(raw string) (string, error) {
+	return defaultNormalizer.Normalize(raw)
+}
+
+// Normalize returns the canonical form of an absolute http(s) URL:
+//   - http and https are replaced by the scheme of the rules
+//   - the host is lowercased, internationalized hosts converted to punycode
+//   - default ports, fragments and tracking parameters are removed
+//   - dot segments are resolved and an empty path becomes /
+//   - percent-encoding uses uppercase hex and unreserved characters are decoded
+//   - query parameters are sorted by name, keeping the order of repeated names
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse url %s: %s", raw, err)
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("Unsupported scheme %q in %s", parsed.Scheme, raw)
+	}
+
+	host, err := normalizeHost(parsed.Hostname())
+	if err != nil {
+		return "", fmt.Errorf("Invalid host in %s: %s", raw, err)
+	}
+
+	port := parsed.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString(n.scheme)
+	builder.WriteString("://")
+
+	if parsed.User != nil {
+		builder.WriteString(parsed.User.String())
+		builder.WriteByte('@')
+	}
+
+	builder.WriteString(host)
+	if port != "" {
+		builder.WriteByte(':')
+		builder.WriteString(port)
+	}
+
+	builder.WriteString(removeDotSegments(normalizeEscapes(parsed.EscapedPath(), pathChars)))
+
+	if query := n.normalizeQuery(parsed.RawQuery); query != "" {
+		builder.WriteByte('?')
+		builder.WriteString(query)
+	}
+
+	return builder.String(), nil
+}
+
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", errors.New("host is empty")
+	}
+
+	if strings.Contains(host, ":") {
+		return "[" + host + "]", nil
+	}
+
+	if unescaped, err := url.PathUnescape(host); err == nil {
+		host = unescaped
+	}
+
+	return idna.Lookup.ToASCII(host)
+}
+
+type queryParam struct {
+	name string
+	raw  string
+}
+
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	var params []queryParam
+	for part := range strings.SplitSeq(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(part, "=")
+		name = normalizeEscapes(name, queryChars)
+		value = normalizeEscapes(value, queryChars)
+
+		if n.stripped(name) {
+			continue
+		}
+
+		raw := name
+		if hasValue {
+			raw += "=" + value
+		}
+
+		params = append(params, queryParam{name: name, raw: raw})
+	}
+
+	slices.SortStableFunc(params, func(a, b queryParam) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	raws := make([]string, 0, len(params))
+	for _, param := range params {
+		raws = append(raws, param.raw)
+	}
+
+	return strings.Join(raws, "&")
+}
+
+func (n *Normalizer) stripped(name string) bool {
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.ToLower(name)
+
+	return !matchesAny(n.keep, name) && matchesAny(n.strip, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	pathChars  = "!$&'()*+,;=:@/"
+	queryChars = "!$'()*+,;=:@/?"
+)
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters, uppercases the remaining
+// escapes and encodes any byte that may not appear literally. allowed lists the reserved
+// characters that stay literal in the component.
+func normalizeEscapes(value string, allowed string) string {
+	const hex = "0123456789ABCDEF"
+
+	var builder strings.Builder
+	builder.Grow(len(value))
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		if c == '%' && i+2 < len(value) {
+			high, okHigh := unhex(value[i+1])
+			low, okLow := unhex(value[i+2])
+
+			if okHigh && okLow {
+				decoded := high<<4 | low
+				if isUnreserved(decoded) {
+					builder.WriteByte(decoded)
+				} else {
+					builder.WriteByte('%')
+					builder.WriteByte(hex[decoded>>4])
+					builder.WriteByte(hex[decoded&15])
+				}
+
+				i += 2
+				continue
+			}
+		}
+
+		if isUnreserved(c) || strings.IndexByte(allowed, c) >= 0 {
+			builder.WriteByte(c)
+			continue
+		}
+
+		builder.WriteByte('%')
+		builder.WriteByte(hex[c>>4])
+		builder.WriteByte(hex[c&15])
+	}
+
+	return builder.String()
+}
+
+// removeDotSegments resolves . and .. segments as described in RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	output := make([]string, 0, len(segments))
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+
+	result := strings.Join(output, "/")
+	if !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+
+	return result
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+
+	return lowered
+}
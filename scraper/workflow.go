@@ -1,7 +1,9 @@
 package scraper
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,17 +12,39 @@ import (
 )
 
 type Robot struct {
-	Text    string   `json:"text"`
-	Sitemap []string `json:"sitemap"`
+	ID           *string  `json:"id,omitempty"`
+	Text         string   `json:"text"`
+	Sitemap      []string `json:"sitemap"`
+	StatusCode   int      `json:"status_code"`
+	Policy       string   `json:"policy"`
+	ETag         *string  `json:"etag,omitempty"`
+	LastModified *string  `json:"last_modified,omitempty"`
+	NotModified  bool     `json:"not_modified"`
+}
+
+// newUploadID returns the upload ID passed by the caller or generates a fresh one.
+// Generation goes through SideEffect so replays see the same ID.
+func newUploadID(ctx workflow.Context, uploadID *string) string {
+	if uploadID != nil && *uploadID != "" {
+		return *uploadID
+	}
+
+	var generated string
+	_ = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return uuid.New().String()
+	}).Get(&generated)
+
+	return generated
 }
 
 type GetEntityRobotsArgs struct {
-	UploadID *string `json:"upload_id,omitempty"`
-	EntityID string  `json:"entity_id"`
-	Url      string  `json:"url"`
+	UploadID *string        `json:"upload_id,omitempty"`
+	EntityID string         `json:"entity_id"`
+	Url      string         `json:"url"`
+	Limits   *SitemapLimits `json:"limits,omitempty"`
 }
 
-func GetEntityRobots(ctx workflow.Context, args GetEntityRobotsArgs) error {
+func GetEntityRobots(ctx workflow.Context, args GetEntityRobotsArgs) (SitemapCrawlResult, error) {
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
 		RetryPolicy: &temporal.RetryPolicy{
@@ -33,41 +57,209 @@ func GetEntityRobots(ctx workflow.Context, args GetEntityRobotsArgs) error {
 
 	var scraperActivities *ScraperActivities
 
-	var robots string
-	err := workflow.ExecuteActivity(ctx, scraperActivities.GetRobots, fmt.Sprintf("%s/robots.txt", args.Url)).Get(ctx, &robots)
+	robotsCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    time.Minute,
+		ScheduleToCloseTimeout: RobotsRetryWindow,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			MaximumInterval:    5 * time.Minute,
+			BackoffCoefficient: 2,
+		},
+	})
+
+	var robots Robot
+	err := workflow.ExecuteActivity(robotsCtx, scraperActivities.GetRobots, GetRobotsArgs{
+		EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+		Url:      fmt.Sprintf("%s/robots.txt", args.Url),
+	}).Get(ctx, &robots)
 	if err != nil {
-		return fmt.Errorf("Failed to get robots.txt: %s", err)
+		var appErr *temporal.ApplicationError
+		if !errors.As(err, &appErr) || appErr.Type() != RobotsUnavailableErrorType {
+			return SitemapCrawlResult{}, fmt.Errorf("Failed to get robots.txt: %s", err)
+		}
+
+		// RFC 9309: an unreachable robots.txt means the whole site is disallowed.
+		workflow.GetLogger(ctx).Warn("robots.txt unreachable, disallowing all", "url", args.Url, "error", err)
+
+		robots = Robot{Policy: RobotsPolicyDisallowAll}
+		if appErr.HasDetails() {
+			_ = appErr.Details(&robots.StatusCode)
+		}
 	}
 
-	var uploadID string
-	if args.UploadID == nil || *args.UploadID == "" {
-		uploadID = uuid.New().String()
+	uploadID := newUploadID(ctx, args.UploadID)
+
+	var robotsID string
+	if robots.NotModified && robots.ID != nil {
+		robotsID = *robots.ID
 	} else {
-		uploadID = *args.UploadID
+		err = workflow.ExecuteActivity(ctx, scraperActivities.SaveRobots, SaveRobotsArgs{
+			UploadID:     uuid.Must(uuid.Parse(uploadID)),
+			EntityID:     uuid.Must(uuid.Parse(args.EntityID)),
+			Body:         robots.Text,
+			StatusCode:   robots.StatusCode,
+			Policy:       robots.Policy,
+			ETag:         robots.ETag,
+			LastModified: robots.LastModified,
+		}).Get(ctx, &robotsID)
+
+		if err != nil {
+			return SitemapCrawlResult{}, fmt.Errorf("Failed to save robots.txt to table: %s", err)
+		}
 	}
 
-	err = workflow.ExecuteActivity(ctx, scraperActivities.SaveRobots, SaveRobotsArgs{
-		UploadID: uuid.Must(uuid.Parse(uploadID)),
-		EntityID: uuid.Must(uuid.Parse(args.EntityID)),
-		Body:     robots,
-	}).Get(ctx, nil)
+	limits := DefaultSitemapLimits()
+	if args.Limits != nil {
+		limits = *args.Limits
+	}
 
-	if err != nil {
-		return fmt.Errorf("Failed to save robots.txt to table: %s", err)
+	fanOut := newSitemapFanOut(ctx, limits)
+	for i, sitemapUrl := range robots.Sitemap {
+		started := fanOut.start(GetEntitySitemapArgs{
+			UploadID: &uploadID,
+			EntityID: args.EntityID,
+			RobotsID: robotsID,
+			Url:      sitemapUrl,
+		}, len(robots.Sitemap)-i)
+
+		if !started {
+			workflow.GetLogger(ctx).Warn("URL budget exhausted, not crawling remaining sitemaps", "url", args.Url)
+			break
+		}
 	}
 
-	return nil
+	return fanOut.wait(), nil
 }
 
 type GetEntitySitemapArgs struct {
-	UploadID *string `json:"upload_id,omitempty"`
-	EntityID string  `json:"entity_id"`
-	RobotsID string  `json:"robots_id"`
-	OriginID *string `json:"origin_id,omitempty"`
-	Url      string  `json:"url"`
+	UploadID *string        `json:"upload_id,omitempty"`
+	EntityID string         `json:"entity_id"`
+	RobotsID string         `json:"robots_id"`
+	OriginID *string        `json:"origin_id,omitempty"`
+	Url      string         `json:"url"`
+	Depth    int            `json:"depth"`
+	Limits   *SitemapLimits `json:"limits,omitempty"`
+
+	// Set when the workflow continues as new while crawling the children of a sitemap index.
+	Children *SitemapChildrenCursor `json:"children,omitempty"`
+}
+
+// SitemapChildrenCursor is where a sitemap index left off crawling its children.
+type SitemapChildrenCursor struct {
+	AfterID *string                     `json:"after_id,omitempty"`
+	Result  SitemapCrawlResult          `json:"result"`
+	Used    int                         `json:"used"`
+	Scraped MarkSitemapIndexScrapedArgs `json:"scraped"`
+}
+
+// SitemapLimits bound the recursive traversal of sitemap indexes.
+// Every child gets its own share of URLBudget, so children in flight together cannot overshoot it.
+type SitemapLimits struct {
+	MaxDepth      int `json:"max_depth"`
+	MaxConcurrent int `json:"max_concurrent"`
+	URLBudget     int `json:"url_budget"`
+}
+
+func DefaultSitemapLimits() SitemapLimits {
+	return SitemapLimits{
+		MaxDepth:      DefaultSitemapMaxDepth,
+		MaxConcurrent: DefaultSitemapMaxConcurrent,
+		URLBudget:     DefaultSitemapURLBudget,
+	}
+}
+
+type SitemapCrawlResult struct {
+	Sitemaps int `json:"sitemaps"`
+	URLs     int `json:"urls"`
+	Errors   int `json:"errors"`
+}
+
+func (r *SitemapCrawlResult) Add(other SitemapCrawlResult) {
+	r.Sitemaps += other.Sitemaps
+	r.URLs += other.URLs
+	r.Errors += other.Errors
 }
 
-func GetEntitySitemap(ctx workflow.Context, args GetEntitySitemapArgs) error {
+// sitemapFanOut runs GetEntitySitemap children with bounded concurrency, sharing a single URL
+// budget between all of them. Each child is handed a share of the budget when it starts; used
+// counts what finished children saved, plus the whole share of children that failed, since they
+// may have saved part of it.
+type sitemapFanOut struct {
+	ctx      workflow.Context
+	limits   SitemapLimits
+	selector workflow.Selector
+	pending  int
+	reserved int
+	used     int
+	result   SitemapCrawlResult
+}
+
+func newSitemapFanOut(ctx workflow.Context, limits SitemapLimits) *sitemapFanOut {
+	return &sitemapFanOut{
+		ctx:      ctx,
+		limits:   limits,
+		selector: workflow.NewSelector(ctx),
+	}
+}
+
+func (f *sitemapFanOut) remaining() int {
+	return f.limits.URLBudget - f.used - f.reserved
+}
+
+// start blocks until a concurrency slot frees up and returns false once the URL budget is exhausted.
+// left is how many sitemaps, including this one, are still to be started. The child gets an
+// equal share of the remaining budget among those that can run at the same time.
+func (f *sitemapFanOut) start(args GetEntitySitemapArgs, left int) bool {
+	maxConcurrent := max(f.limits.MaxConcurrent, 1)
+	for f.pending >= maxConcurrent {
+		f.selector.Select(f.ctx)
+	}
+
+	share := f.remaining() / max(min(maxConcurrent, left), 1)
+	if share <= 0 {
+		return false
+	}
+
+	limits := f.limits
+	limits.URLBudget = share
+	args.Limits = &limits
+
+	future := workflow.ExecuteChildWorkflow(f.ctx, GetEntitySitemap, args)
+	f.pending++
+	f.reserved += share
+
+	f.selector.AddFuture(future, func(future workflow.Future) {
+		f.pending--
+		f.reserved -= share
+
+		var result SitemapCrawlResult
+		err := future.Get(f.ctx, &result)
+		if err != nil {
+			workflow.GetLogger(f.ctx).Error("Failed to get sitemap", "url", args.Url, "error", err)
+			f.used += share
+			f.result.Errors++
+			return
+		}
+
+		f.used += result.URLs
+		f.result.Add(result)
+	})
+
+	return true
+}
+
+func (f *sitemapFanOut) wait() SitemapCrawlResult {
+	for f.pending > 0 {
+		f.selector.Select(f.ctx)
+	}
+
+	return f.result
+}
+
+// GetEntitySitemap fetches a single sitemap and recursively crawls sitemap index entries.
+// OriginID is the sitemap_index row describing args.Url; root sitemaps register their own row.
+// A sitemap index continues as new every SitemapChildrenPerRun children to keep its history bounded.
+func GetEntitySitemap(ctx workflow.Context, args GetEntitySitemapArgs) (SitemapCrawlResult, error) {
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
 		RetryPolicy: &temporal.RetryPolicy{
@@ -80,50 +272,702 @@ func GetEntitySitemap(ctx workflow.Context, args GetEntitySitemapArgs) error {
 
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
+	// Fetching, parsing and saving a 50 MB sitemap takes longer than the other activities,
+	// so these heartbeat instead of relying on a short start-to-close timeout.
+	ao.StartToCloseTimeout = SitemapActivityTimeout
+	ao.HeartbeatTimeout = SitemapHeartbeatTimeout
+	sitemapCtx := workflow.WithActivityOptions(ctx, ao)
+
 	var scraperActivities *ScraperActivities
+	var result SitemapCrawlResult
+
+	limits := DefaultSitemapLimits()
+	if args.Limits != nil {
+		limits = *args.Limits
+	}
+
+	uploadID := newUploadID(ctx, args.UploadID)
+	args.UploadID = &uploadID
+
+	if args.Children != nil {
+		return crawlSitemapIndex(ctx, args, limits)
+	}
+
+	if args.OriginID == nil {
+		var rootID string
+		err := workflow.ExecuteActivity(ctx, scraperActivities.SaveSitemapRoot, SaveSitemapRootArgs{
+			UploadID: uuid.Must(uuid.Parse(uploadID)),
+			EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+			RobotsID: uuid.Must(uuid.Parse(args.RobotsID)),
+			Url:      args.Url,
+		}).Get(ctx, &rootID)
+
+		if err != nil {
+			return result, fmt.Errorf("Failed to save root sitemap to table: %s", err)
+		}
+
+		args.OriginID = &rootID
+	}
+
+	originID := uuid.Must(uuid.Parse(*args.OriginID))
+
+	var allowed bool
+	err := workflow.ExecuteActivity(ctx, scraperActivities.CheckAllowed, CheckAllowedArgs{
+		UploadID: uuid.Must(uuid.Parse(uploadID)),
+		EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+		Url:      args.Url,
+	}).Get(ctx, &allowed)
+	if err != nil {
+		return result, fmt.Errorf("Failed to check robots.txt rules: %s", err)
+	}
+
+	if !allowed {
+		workflow.GetLogger(ctx).Info("Sitemap disallowed by robots.txt, skipping", "url", args.Url)
+		return result, markSitemapIndexScraped(ctx, MarkSitemapIndexScrapedArgs{ID: originID})
+	}
 
 	var sitemapRes SitemapRes
-	err := workflow.ExecuteActivity(ctx, scraperActivities.GetSitemap, args.Url).Get(ctx, &sitemapRes)
+	err = workflow.ExecuteActivity(sitemapCtx, scraperActivities.GetSitemap, GetSitemapArgs{
+		EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+		Url:      args.Url,
+	}).Get(ctx, &sitemapRes)
 	if err != nil {
-		return fmt.Errorf("Failed to get sitemaps: %s", err)
+		return result, fmt.Errorf("Failed to get sitemaps: %s", err)
 	}
 
-	var uploadID string
-	if args.UploadID == nil || *args.UploadID == "" {
-		uploadID = uuid.New().String()
-	} else {
-		uploadID = *args.UploadID
+	scraped := MarkSitemapIndexScrapedArgs{
+		ID:           originID,
+		ETag:         sitemapRes.ETag,
+		LastModified: sitemapRes.LastModified,
 	}
 
-	var originID *uuid.UUID
-	if args.OriginID == nil {
-		originID = nil
-	} else {
-		UUID := uuid.Must(uuid.Parse(*args.OriginID))
-		originID = &UUID
+	// An unchanged sitemap index implies unchanged children, since generators bump
+	// the index lastmod whenever one of its sitemaps changes.
+	if sitemapRes.Type == "not_modified" {
+		workflow.GetLogger(ctx).Info("Sitemap not modified, skipping", "url", args.Url)
+		return result, markSitemapIndexScraped(ctx, scraped)
 	}
 
+	result.Sitemaps++
+
 	data := SaveSitemapArgs{
 		UploadID: uuid.Must(uuid.Parse(uploadID)),
 		EntityID: uuid.Must(uuid.Parse(args.EntityID)),
 		RobotsID: uuid.Must(uuid.Parse(args.RobotsID)),
-		OriginID: originID,
-		SaveID:   sitemapRes.SaveID,
+		OriginID: &originID,
+		SaveIDs:  sitemapRes.SaveIDs,
+		Limit:    limits.URLBudget,
 	}
 
 	if sitemapRes.Type == "index" {
-		err = workflow.ExecuteActivity(ctx, scraperActivities.SaveSitemapIndex, data).Get(ctx, nil)
+		err = workflow.ExecuteActivity(sitemapCtx, scraperActivities.SaveSitemapIndex, data).Get(ctx, nil)
 
 		if err != nil {
-			return fmt.Errorf("Failed to save sitemap index to table: %s", err)
+			return result, fmt.Errorf("Failed to save sitemap index to table: %s", err)
+		}
+
+		deletePayloads(ctx, sitemapRes.SaveIDs)
+
+		if args.Depth >= limits.MaxDepth {
+			workflow.GetLogger(ctx).Warn("Sitemap index too deep, not crawling children", "url", args.Url, "depth", args.Depth)
+		} else {
+			args.Children = &SitemapChildrenCursor{Result: result, Scraped: scraped}
+			return crawlSitemapIndex(ctx, args, limits)
 		}
 	} else if sitemapRes.Type == "urlset" {
-		err = workflow.ExecuteActivity(ctx, scraperActivities.SaveSitemapUrlset, data).Get(ctx, nil)
+		var saved int
+		err = workflow.ExecuteActivity(sitemapCtx, scraperActivities.SaveSitemapUrlset, data).Get(ctx, &saved)
+
+		if err != nil {
+			return result, fmt.Errorf("Failed to save sitemap urlset to table: %s", err)
+		}
 
+		deletePayloads(ctx, sitemapRes.SaveIDs)
+
+		result.URLs += saved
+	}
+
+	return result, markSitemapIndexScraped(ctx, scraped)
+}
+
+// deletePayloads cleans up saved sitemap chunks. Failing to do so only leaves garbage behind,
+// so it is logged instead of failing the crawl.
+func deletePayloads(ctx workflow.Context, keys []string) {
+	var scraperActivities *ScraperActivities
+
+	for _, key := range keys {
+		err := workflow.ExecuteActivity(ctx, scraperActivities.DeletePayload, DeletePayloadArgs{Key: key}).Get(ctx, nil)
 		if err != nil {
-			return fmt.Errorf("Failed to save sitemap urlset to table: %s", err)
+			workflow.GetLogger(ctx).Warn("Failed to delete sitemap payload", "key", key, "error", err)
 		}
 	}
+}
+
+// crawlSitemapIndex starts a child for every entry of the sitemap index args.OriginID after the
+// cursor in args.Children. Once SitemapChildrenPerRun children have finished it continues as new
+// with the cursor moved past them; the last run marks the index as scraped.
+func crawlSitemapIndex(ctx workflow.Context, args GetEntitySitemapArgs, limits SitemapLimits) (SitemapCrawlResult, error) {
+	var scraperActivities *ScraperActivities
+
+	cursor := args.Children
+	originID := uuid.Must(uuid.Parse(*args.OriginID))
+
+	fanOut := newSitemapFanOut(ctx, limits)
+	fanOut.used = cursor.Used
+	started := 0
+
+	finish := func() SitemapCrawlResult {
+		result := cursor.Result
+		result.Add(fanOut.wait())
+		return result
+	}
+
+pages:
+	for {
+		var afterID *uuid.UUID
+		if cursor.AfterID != nil {
+			UUID := uuid.Must(uuid.Parse(*cursor.AfterID))
+			afterID = &UUID
+		}
+
+		var entries []SitemapIndexEntry
+		err := workflow.ExecuteActivity(ctx, scraperActivities.ListSitemapIndex, ListSitemapIndexArgs{
+			UploadID: uuid.Must(uuid.Parse(*args.UploadID)),
+			EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+			OriginID: originID,
+			AfterID:  afterID,
+			Limit:    SitemapIndexPageSize,
+		}).Get(ctx, &entries)
+
+		if err != nil {
+			return finish(), fmt.Errorf("Failed to list sitemap index: %s", err)
+		}
+
+		for i, entry := range entries {
+			if started >= SitemapChildrenPerRun {
+				cursor.Result = finish()
+				cursor.Used = fanOut.used
+
+				return cursor.Result, workflow.NewContinueAsNewError(ctx, GetEntitySitemap, args)
+			}
+
+			childOriginID := entry.ID.String()
+			ok := fanOut.start(GetEntitySitemapArgs{
+				UploadID: args.UploadID,
+				EntityID: args.EntityID,
+				RobotsID: args.RobotsID,
+				OriginID: &childOriginID,
+				Url:      entry.Url,
+				Depth:    args.Depth + 1,
+			}, len(entries)-i)
+
+			if !ok {
+				workflow.GetLogger(ctx).Warn("URL budget exhausted, not crawling remaining sitemaps", "url", args.Url)
+				break pages
+			}
+
+			started++
+			cursor.AfterID = &childOriginID
+		}
+
+		if len(entries) < SitemapIndexPageSize {
+			break
+		}
+	}
+
+	result := finish()
+
+	return result, markSitemapIndexScraped(ctx, cursor.Scraped)
+}
+
+func markSitemapIndexScraped(ctx workflow.Context, args MarkSitemapIndexScrapedArgs) error {
+	var scraperActivities *ScraperActivities
+
+	err := workflow.ExecuteActivity(ctx, scraperActivities.MarkSitemapIndexScraped, args).Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to mark sitemap index as scraped: %s", err)
+	}
 
 	return nil
 }
+
+type FetchEntityPagesArgs struct {
+	EntityID      string           `json:"entity_id"`
+	UploadID      *string          `json:"upload_id,omitempty"`
+	AfterID       *string          `json:"after_id,omitempty"`
+	BatchSize     int              `json:"batch_size"`
+	MaxConcurrent int              `json:"max_concurrent"`
+	Result        FetchPagesResult `json:"result"`
+}
+
+type FetchPagesResult struct {
+	Pages      int `json:"pages"`
+	Documents  int `json:"documents"`
+	Discovered int `json:"discovered"`
+	Disallowed int `json:"disallowed"`
+	Errors     int `json:"errors"`
+}
+
+func (r *FetchPagesResult) Add(other FetchPagesResult) {
+	r.Pages += other.Pages
+	r.Documents += other.Documents
+	r.Discovered += other.Discovered
+	r.Disallowed += other.Disallowed
+	r.Errors += other.Errors
+}
+
+// FetchEntityPages pages through unscraped sitemap_urlset rows of an entity and fetches them.
+// Failed fetches stay unscraped and are picked up by the next run. The workflow continues as new
+// every PageBatchesPerRun batches to keep its history bounded.
+func FetchEntityPages(ctx workflow.Context, args FetchEntityPagesArgs) (FetchPagesResult, error) {
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			MaximumInterval:    time.Minute,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	var scraperActivities *ScraperActivities
+
+	if args.BatchSize <= 0 {
+		args.BatchSize = DefaultPageBatchSize
+	}
+
+	if args.MaxConcurrent <= 0 {
+		args.MaxConcurrent = DefaultPageMaxConcurrent
+	}
+
+	var uploadID *uuid.UUID
+	if args.UploadID != nil && *args.UploadID != "" {
+		UUID := uuid.Must(uuid.Parse(*args.UploadID))
+		uploadID = &UUID
+	}
+
+	for batch := 0; batch < PageBatchesPerRun; batch++ {
+		var afterID *uuid.UUID
+		if args.AfterID != nil {
+			UUID := uuid.Must(uuid.Parse(*args.AfterID))
+			afterID = &UUID
+		}
+
+		var entries []UrlsetEntry
+		err := workflow.ExecuteActivity(ctx, scraperActivities.ListUnscrapedUrlset, ListUnscrapedUrlsetArgs{
+			EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+			UploadID: uploadID,
+			AfterID:  afterID,
+			Limit:    args.BatchSize,
+		}).Get(ctx, &entries)
+
+		if err != nil {
+			return args.Result, fmt.Errorf("Failed to list unscraped urlset: %s", err)
+		}
+
+		args.Result.Add(fetchPages(ctx, args.EntityID, entries, args.MaxConcurrent))
+
+		if len(entries) < args.BatchSize {
+			return args.Result, nil
+		}
+
+		lastID := entries[len(entries)-1].ID.String()
+		args.AfterID = &lastID
+	}
+
+	return args.Result, workflow.NewContinueAsNewError(ctx, FetchEntityPages, args)
+}
+
+func fetchPages(ctx workflow.Context, entityID string, entries []UrlsetEntry, maxConcurrent int) FetchPagesResult {
+	var scraperActivities *ScraperActivities
+	var result FetchPagesResult
+
+	// A busy host is waited out here on a timer instead of burning retry attempts.
+	pageOptions := workflow.GetActivityOptions(ctx)
+	pageOptions.StartToCloseTimeout = FetchPageTimeout
+	if pageOptions.RetryPolicy != nil {
+		retryPolicy := *pageOptions.RetryPolicy
+		retryPolicy.NonRetryableErrorTypes = append(slices.Clone(retryPolicy.NonRetryableErrorTypes), RateLimitedErrorType)
+		pageOptions.RetryPolicy = &retryPolicy
+	}
+	pageCtx := workflow.WithActivityOptions(ctx, pageOptions)
+
+	selector := workflow.NewSelector(ctx)
+	pending := 0
+
+	var fetch func(entry UrlsetEntry, deferrals int)
+	fetch = func(entry UrlsetEntry, deferrals int) {
+		future := workflow.ExecuteActivity(pageCtx, scraperActivities.FetchPage, FetchPageArgs{
+			EntityID: uuid.Must(uuid.Parse(entityID)),
+			UploadID: entry.UploadID,
+			UrlsetID: entry.ID,
+			Url:      entry.Url,
+		})
+
+		selector.AddFuture(future, func(future workflow.Future) {
+			var res FetchPageRes
+			err := future.Get(ctx, &res)
+
+			if retryAfter, ok := hostRetryAfter(err); ok && deferrals < MaxHostWaitDeferrals {
+				selector.AddFuture(workflow.NewTimer(ctx, retryAfter), func(workflow.Future) {
+					fetch(entry, deferrals+1)
+				})
+				return
+			}
+
+			pending--
+
+			if err != nil {
+				workflow.GetLogger(ctx).Error("Failed to fetch page", "url", entry.Url, "error", err)
+				result.Errors++
+				return
+			}
+
+			if res.Disallowed {
+				result.Disallowed++
+				return
+			}
+
+			result.Pages++
+
+			if res.PageID == nil {
+				return
+			}
+
+			// The page is already stored, so a failed extraction does not fail the fetch.
+			extract := workflow.ExecuteActivity(ctx, scraperActivities.ExtractDocument, ExtractDocumentArgs{
+				PageID: uuid.Must(uuid.Parse(*res.PageID)),
+			})
+			pending++
+
+			selector.AddFuture(extract, func(future workflow.Future) {
+				pending--
+
+				var res ExtractDocumentRes
+				err := future.Get(ctx, &res)
+				if err != nil {
+					workflow.GetLogger(ctx).Error("Failed to extract document", "url", entry.Url, "error", err)
+					result.Errors++
+					return
+				}
+
+				if !res.Skipped {
+					result.Documents++
+					result.Discovered += res.Discovered
+				}
+			})
+		})
+	}
+
+	for _, entry := range entries {
+		for pending >= maxConcurrent {
+			selector.Select(ctx)
+		}
+
+		fetch(entry, 0)
+		pending++
+	}
+
+	for pending > 0 {
+		selector.Select(ctx)
+	}
+
+	return result
+}
+
+const (
+	CrawlProgressQuery = "progress"
+	CrawlPauseSignal   = "pause"
+	CrawlResumeSignal  = "resume"
+	CrawlCancelSignal  = "cancel"
+
+	CrawlPhaseSitemaps = "sitemaps"
+	CrawlPhasePages    = "pages"
+	CrawlPhaseIndex    = "index"
+	CrawlPhaseDone     = "done"
+)
+
+type CrawlProgress struct {
+	UploadID   string `json:"upload_id"`
+	Phase      string `json:"phase"`
+	Paused     bool   `json:"paused"`
+	Sitemaps   int    `json:"sitemaps"`
+	URLs       int    `json:"urls"`
+	Pages      int    `json:"pages"`
+	Documents  int    `json:"documents"`
+	Discovered int    `json:"discovered"`
+	Disallowed int    `json:"disallowed"`
+	Indexed    int    `json:"indexed"`
+	Errors     int    `json:"errors"`
+}
+
+type CrawlEntityArgs struct {
+	EntityID string         `json:"entity_id"`
+	Url      string         `json:"url"`
+	Limits   *SitemapLimits `json:"limits,omitempty"`
+
+	// Set when the workflow continues as new in the middle of a crawl.
+	UploadID *string        `json:"upload_id,omitempty"`
+	AfterID  *string        `json:"after_id,omitempty"`
+	Progress *CrawlProgress `json:"progress,omitempty"`
+
+	// URLs discovered through links since the pages phase last started from the beginning.
+	PassDiscovered int `json:"pass_discovered,omitempty"`
+}
+
+// CrawlEntity runs robots.txt → sitemaps → pages → index for one entity under a fresh upload ID, so
+// every scheduled run produces its own set of rows. Progress is exposed through the CrawlProgressQuery
+// query. Pause takes effect before the next phase or batch; cancel stops in-flight children.
+func CrawlEntity(ctx workflow.Context, args CrawlEntityArgs) (CrawlProgress, error) {
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			MaximumInterval:    time.Minute,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	var scraperActivities *ScraperActivities
+
+	progress := CrawlProgress{Phase: CrawlPhaseSitemaps}
+	if args.Progress != nil {
+		progress = *args.Progress
+	}
+
+	progress.UploadID = newUploadID(ctx, args.UploadID)
+	args.UploadID = &progress.UploadID
+
+	err := workflow.SetQueryHandler(ctx, CrawlProgressQuery, func() (CrawlProgress, error) {
+		return progress, nil
+	})
+	if err != nil {
+		return progress, fmt.Errorf("Failed to register progress query: %s", err)
+	}
+
+	crawlCtx, cancel := workflow.WithCancel(ctx)
+	cancelled := false
+
+	pauseChannel := workflow.GetSignalChannel(ctx, CrawlPauseSignal)
+	resumeChannel := workflow.GetSignalChannel(ctx, CrawlResumeSignal)
+	cancelChannel := workflow.GetSignalChannel(ctx, CrawlCancelSignal)
+
+	signals := workflow.NewSelector(ctx)
+	signals.AddReceive(pauseChannel, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		progress.Paused = true
+	})
+	signals.AddReceive(resumeChannel, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		progress.Paused = false
+	})
+	signals.AddReceive(cancelChannel, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		cancelled = true
+		cancel()
+	})
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for !cancelled {
+			signals.Select(ctx)
+		}
+	})
+
+	waitForResume := func() error {
+		err := workflow.Await(ctx, func() bool {
+			return !progress.Paused || cancelled
+		})
+		if err != nil {
+			return err
+		}
+
+		if cancelled {
+			workflow.GetLogger(ctx).Info("Entity crawl cancelled", "entity_id", args.EntityID, "phase", progress.Phase)
+			return temporal.NewCanceledError(progress)
+		}
+
+		return nil
+	}
+
+	if progress.Phase == CrawlPhaseSitemaps {
+		workflow.GetLogger(ctx).Info("Starting entity crawl", "entity_id", args.EntityID, "upload_id", progress.UploadID)
+
+		err = waitForResume()
+		if err != nil {
+			return progress, err
+		}
+
+		before := progress
+
+		child := workflow.ExecuteChildWorkflow(crawlCtx, GetEntityRobots, GetEntityRobotsArgs{
+			UploadID: &progress.UploadID,
+			EntityID: args.EntityID,
+			Url:      args.Url,
+			Limits:   args.Limits,
+		})
+
+		// The children do not report every sitemap they save, that would grow their history and
+		// ours with each one. Instead the rows saved for the upload are counted on a timer.
+		for !child.IsReady() {
+			timerCtx, cancelTimer := workflow.WithCancel(crawlCtx)
+			selector := workflow.NewSelector(ctx)
+			selector.AddFuture(child, func(workflow.Future) {
+				cancelTimer()
+			})
+			selector.AddFuture(workflow.NewTimer(timerCtx, SitemapProgressInterval), func(workflow.Future) {
+				var counted SitemapCrawlResult
+				err := workflow.ExecuteActivity(crawlCtx, scraperActivities.CountSitemapProgress, CountSitemapProgressArgs{
+					EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+					UploadID: uuid.Must(uuid.Parse(progress.UploadID)),
+				}).Get(crawlCtx, &counted)
+
+				if err != nil {
+					workflow.GetLogger(ctx).Warn("Failed to count sitemap progress", "entity_id", args.EntityID, "error", err)
+					return
+				}
+
+				progress.Sitemaps = before.Sitemaps + counted.Sitemaps
+				progress.URLs = before.URLs + counted.URLs
+			})
+			selector.Select(ctx)
+		}
+
+		var result SitemapCrawlResult
+		err = child.Get(crawlCtx, &result)
+
+		if cancelled {
+			return progress, waitForResume()
+		}
+
+		if err != nil {
+			return progress, fmt.Errorf("Failed to crawl robots.txt and sitemaps: %s", err)
+		}
+
+		// The counts taken while the child ran are replaced by its result.
+		progress.Sitemaps = before.Sitemaps + result.Sitemaps
+		progress.URLs = before.URLs + result.URLs
+		progress.Errors += result.Errors
+		progress.Phase = CrawlPhasePages
+	}
+
+	uploadID := uuid.Must(uuid.Parse(progress.UploadID))
+	batches := 0
+
+	for progress.Phase == CrawlPhasePages && batches < PageBatchesPerRun {
+		batches++
+
+		err = waitForResume()
+		if err != nil {
+			return progress, err
+		}
+
+		var afterID *uuid.UUID
+		if args.AfterID != nil {
+			UUID := uuid.Must(uuid.Parse(*args.AfterID))
+			afterID = &UUID
+		}
+
+		var entries []UrlsetEntry
+		err = workflow.ExecuteActivity(crawlCtx, scraperActivities.ListUnscrapedUrlset, ListUnscrapedUrlsetArgs{
+			EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+			UploadID: &uploadID,
+			AfterID:  afterID,
+			Limit:    DefaultPageBatchSize,
+		}).Get(crawlCtx, &entries)
+
+		if cancelled {
+			return progress, waitForResume()
+		}
+
+		if err != nil {
+			return progress, fmt.Errorf("Failed to list unscraped urlset: %s", err)
+		}
+
+		pages := fetchPages(crawlCtx, args.EntityID, entries, DefaultPageMaxConcurrent)
+		progress.Pages += pages.Pages
+		progress.Documents += pages.Documents
+		progress.Discovered += pages.Discovered
+		progress.Disallowed += pages.Disallowed
+		progress.Errors += pages.Errors
+
+		if cancelled {
+			return progress, waitForResume()
+		}
+
+		args.PassDiscovered += pages.Discovered
+
+		// Discovered rows get random IDs and may sort before the cursor, so keep passing over the
+		// urlset until a pass finds nothing new. MaxLinkDepth keeps this finite.
+		if len(entries) < DefaultPageBatchSize && args.PassDiscovered > 0 {
+			args.AfterID = nil
+			args.PassDiscovered = 0
+			continue
+		}
+
+		if len(entries) < DefaultPageBatchSize {
+			progress.Phase = CrawlPhaseIndex
+			continue
+		}
+
+		lastID := entries[len(entries)-1].ID.String()
+		args.AfterID = &lastID
+	}
+
+	indexCtx := workflow.WithActivityOptions(crawlCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: IndexActivityTimeout,
+		RetryPolicy:         ao.RetryPolicy,
+	})
+
+	for progress.Phase == CrawlPhaseIndex && batches < PageBatchesPerRun {
+		batches++
+
+		err = waitForResume()
+		if err != nil {
+			return progress, err
+		}
+
+		var indexed IndexDocumentsRes
+		err = workflow.ExecuteActivity(indexCtx, scraperActivities.IndexDocuments, IndexDocumentsArgs{
+			EntityID: uuid.Must(uuid.Parse(args.EntityID)),
+			Limit:    DefaultIndexBatchSize,
+		}).Get(crawlCtx, &indexed)
+
+		if cancelled {
+			return progress, waitForResume()
+		}
+
+		if err != nil {
+			return progress, fmt.Errorf("Failed to index documents: %s", err)
+		}
+
+		progress.Indexed += indexed.Indexed
+
+		if indexed.Indexed < DefaultIndexBatchSize {
+			progress.Phase = CrawlPhaseDone
+			workflow.GetLogger(ctx).Info("Entity crawl finished", "entity_id", args.EntityID, "upload_id", progress.UploadID)
+
+			return progress, nil
+		}
+	}
+
+	// Signals that arrived after the last check would be lost with the old run.
+	for pauseChannel.ReceiveAsync(nil) {
+		progress.Paused = true
+	}
+
+	for resumeChannel.ReceiveAsync(nil) {
+		progress.Paused = false
+	}
+
+	if cancelChannel.ReceiveAsync(nil) {
+		cancelled = true
+		return progress, waitForResume()
+	}
+
+	args.Progress = &progress
+
+	return progress, workflow.NewContinueAsNewError(ctx, CrawlEntity, args)
+}